@@ -1,15 +1,55 @@
 package main
 
 import (
+	"fmt"
+	"vcr/internal/config"
 	"vcr/internal/dbs"
 	"vcr/internal/runtime"
 	"vcr/internal/runtime/docker"
+	"vcr/internal/runtime/kubernetes"
+	"vcr/internal/runtime/podman"
+	"vcr/internal/runtime/process"
 )
 
-func buildRuntime() (runtime.ContainerRuntime, error) {
-	return docker.NewDockerClient()
+func buildRuntime(conf config.VcrConfig) (runtime.ContainerRuntime, error) {
+	switch conf.RuntimeImpl {
+	case config.RuntimeImplDocker:
+		return docker.NewDockerClient(conf.Docker)
+	case config.RuntimeImplPodman:
+		return podman.NewPodmanClient(conf.Podman)
+	case config.RuntimeImplProcess:
+		return process.NewProcessRuntime(conf.Process.Binary)
+	case config.RuntimeImplKubernetes:
+		return kubernetes.NewKubernetesClient(conf.Kubernetes)
+	default:
+		return nil, fmt.Errorf("unknown runtime impl %q", conf.RuntimeImpl)
+	}
 }
 
-func buildDb() (dbs.Db, error) {
-	return dbs.NewMemoryDb(), nil
+func buildDb(conf config.DbConfig) (dbs.Db, error) {
+	switch conf.Impl {
+	case config.DbImplMemory:
+		return dbs.NewMemoryDb(), nil
+	case config.DbImplSqlite:
+		return dbs.NewSqliteDb(conf.SqlitePath)
+	case config.DbImplYaml:
+		return dbs.NewYamlDb(conf.YamlPath)
+	default:
+		return nil, fmt.Errorf("unknown db impl %q", conf.Impl)
+	}
+}
+
+func buildHistory(conf config.DbConfig, db dbs.Db) (dbs.History, error) {
+	switch conf.HistoryImpl {
+	case config.DbImplMemory:
+		return dbs.NewMemoryHistory(), nil
+	case config.DbImplSqlite:
+		// share the connection if programmings are stored in the same database
+		if sqlite, ok := db.(*dbs.SqliteDb); ok {
+			return sqlite, nil
+		}
+		return dbs.NewSqliteDb(conf.SqlitePath)
+	default:
+		return nil, fmt.Errorf("unknown history impl %q", conf.HistoryImpl)
+	}
 }
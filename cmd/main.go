@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"flag"
 	"os"
 	"os/signal"
 	"sync"
@@ -19,31 +20,103 @@ import (
 type deps struct {
 	runtime runtime.ContainerRuntime
 	db      dbs.Db
+	history dbs.History
 }
 
-func run(deps *deps, conf config.ContainerConfig) {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Minute*5)
+const (
+	pullTimeout = 5 * time.Minute
+	// startupTimeout limits each of the calls to the runtime on startup besides pulling
+	startupTimeout = time.Minute
+	// adoptAttempts is the amount of attempts to adopt running containers before startup fails
+	adoptAttempts = 5
+	adoptBackoff  = 5 * time.Second
+)
+
+func run(deps *deps, conf config.VcrConfig) {
 	log.Info().Msg("Pulling image...")
-	defer cancel()
-	if err := deps.runtime.Pull(ctx, conf.Image); err != nil {
+	pullCtx, cancelPull := context.WithTimeout(context.Background(), pullTimeout)
+	if err := deps.runtime.Pull(pullCtx, conf.ContainerConfig.Image); err != nil {
 		log.Error().Err(err).Msg("could not pull image")
 	}
+	cancelPull()
 	log.Info().Msg("Done pulling image")
 
-	vcr, err := internal.NewVcr(deps.db, deps.runtime, conf)
+	janitor, err := internal.NewJanitor(deps.runtime, conf.Cleanup)
+	if err != nil {
+		log.Fatal().Err(err).Msg("can not build janitor")
+	}
+
+	log.Info().Msg("Cleaning up stale containers...")
+	sweepCtx, cancelSweep := context.WithTimeout(context.Background(), startupTimeout)
+	if err := janitor.Sweep(sweepCtx); err != nil {
+		log.Error().Err(err).Msg("could not clean up stale containers")
+	}
+	cancelSweep()
+
+	hostPath := ""
+	if conf.ContainerConfig.Mount != nil {
+		hostPath = conf.ContainerConfig.Mount.HostPath
+	}
+	diskGuard, err := internal.NewDiskGuard(hostPath, conf.Disk)
+	if err != nil {
+		log.Fatal().Err(err).Msg("can not build disk guard")
+	}
+
+	retention, err := internal.NewRetention(hostPath, conf.Retention)
+	if err != nil {
+		log.Fatal().Err(err).Msg("can not build retention")
+	}
+
+	loc, err := conf.Location()
+	if err != nil {
+		log.Fatal().Err(err).Msg("invalid timezone")
+	}
+
+	vcr, err := internal.NewVcr(deps.db, deps.runtime, conf.ContainerConfig,
+		internal.WithLocation(loc),
+		internal.WithLeadTime(conf.Scheduler.LeadTime),
+		internal.WithMaxDuration(conf.Scheduler.MaxDuration),
+		internal.WithCatchUpPolicy(conf.Scheduler.CatchUpPolicy),
+		internal.WithPadding(config.Padding{Before: conf.Scheduler.PaddingBefore, After: conf.Scheduler.PaddingAfter}),
+		internal.WithConcurrencyLimit(conf.Scheduler.MaxConcurrentRecordings, conf.Scheduler.OverlapPolicy),
+		internal.WithHistory(deps.history),
+		internal.WithJanitor(janitor),
+		internal.WithDiskGuard(diskGuard),
+		internal.WithRetention(retention),
+	)
 	if err != nil {
 		log.Fatal().Err(err).Msg("can not build vcr")
 	}
 
-	server, err := http.New(":9999", vcr)
+	// recordings of containers that are not adopted would be started a second time
+	log.Info().Msg("Adopting running containers...")
+	if err := adoptContainers(vcr); err != nil {
+		log.Fatal().Err(err).Msg("could not adopt running containers")
+	}
+
+	var serverOpts []http.WebhookOpts
+	if len(conf.Http.TlsCertFile) > 0 {
+		serverOpts = append(serverOpts, http.WithTLS(conf.Http.TlsCertFile, conf.Http.TlsKeyFile))
+	}
+
+	server, err := http.New(conf.Http.Address, vcr, serverOpts...)
 	if err != nil {
 		log.Fatal().Err(err).Msg("could not build http server")
 	}
 
-	ctx, cancel = context.WithCancel(context.Background())
+	ctx, cancel := context.WithCancel(context.Background())
 	wg := &sync.WaitGroup{}
 	go server.Listen(ctx, wg)
 	go vcr.ControlLoop(ctx, wg)
+	go vcr.MonitorDiskSpace(ctx, wg)
+	go retention.Run(ctx, wg)
+	if watcher, ok := deps.db.(dbs.Watcher); ok {
+		go func() {
+			if err := watcher.Watch(ctx, wg, vcr.Reload); err != nil {
+				log.Error().Err(err).Msg("could not watch db for changes")
+			}
+		}()
+	}
 
 	c := make(chan os.Signal, 1)
 	signal.Notify(c, syscall.SIGINT, syscall.SIGTERM)
@@ -54,21 +127,31 @@ func run(deps *deps, conf config.ContainerConfig) {
 	wg.Wait()
 }
 
-func main() {
-	deps := &deps{}
+// adoptContainers adopts the running containers, retrying with a growing backoff if the runtime is not available.
+func adoptContainers(vcr *internal.Vcr) error {
 	var err error
+	for attempt := 1; attempt <= adoptAttempts; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), startupTimeout)
+		err = vcr.AdoptContainers(ctx)
+		cancel()
+		if err == nil {
+			return nil
+		}
 
-	deps.runtime, err = buildRuntime()
-	if err != nil {
-		log.Fatal().Err(err).Msg("could not build runtime")
+		if attempt < adoptAttempts {
+			backoff := time.Duration(attempt) * adoptBackoff
+			log.Warn().Err(err).Msgf("Could not adopt running containers, retrying in %v", backoff)
+			time.Sleep(backoff)
+		}
 	}
+	return err
+}
 
-	deps.db, err = buildDb()
-	if err != nil {
-		log.Fatal().Err(err).Msg("could not build db")
-	}
+func main() {
+	configFile := flag.String("config", "", "path of the yaml config file, environment variables override its values")
+	flag.Parse()
 
-	conf, err := config.GetContainerConfig()
+	conf, err := config.GetConfig(*configFile)
 	if err != nil {
 		log.Fatal().Err(err).Msg("could not get config")
 	}
@@ -77,5 +160,21 @@ func main() {
 		log.Fatal().Err(err).Msg("invalid config")
 	}
 
+	deps := &deps{}
+	deps.runtime, err = buildRuntime(conf)
+	if err != nil {
+		log.Fatal().Err(err).Msg("could not build runtime")
+	}
+
+	deps.db, err = buildDb(conf.Db)
+	if err != nil {
+		log.Fatal().Err(err).Msg("could not build db")
+	}
+
+	deps.history, err = buildHistory(conf.Db, deps.db)
+	if err != nil {
+		log.Fatal().Err(err).Msg("could not build history")
+	}
+
 	run(deps, conf)
 }
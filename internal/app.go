@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"reflect"
 	"sync"
 	"time"
 	"vcr/internal/config"
@@ -12,25 +13,58 @@ import (
 	"vcr/internal/runtime"
 
 	"github.com/rs/zerolog/log"
+	"go.uber.org/multierr"
 )
 
 var ErrValidationError = errors.New("error validating input")
+var ErrNotFound = errors.New("programming not found")
+var ErrConflict = errors.New("programming exceeds the limit of concurrent recordings")
+var ErrAlreadyExists = errors.New("programming already exists")
+
+const (
+	defaultLeadTime     = 30 * time.Second
+	reloadRetryInterval = time.Minute
+	// catchUpLookback limits how far into the past missed occurrences are searched for
+	catchUpLookback = 7 * 24 * time.Hour
+)
 
 type ScheduledRecording struct {
-	done      chan bool
-	recording *Recorder
+	done       chan bool
+	recording  *Recorder
+	occurrence config.Occurrence
+	// programming is the state of the programming the recording has been created or rescheduled for
+	programming config.Programming
+	// containerId is the id of the container the recording has adopted, it's empty if vcr started the recording
+	containerId string
 }
 
 type Vcr struct {
 	db           dbs.Db
+	history      dbs.History
 	programmings map[string]ScheduledRecording
+	mutex        sync.Mutex
 
 	wg            *sync.WaitGroup
 	runtime       runtime.ContainerRuntime
 	containerConf config.ContainerConfig
+	location      *time.Location
+	scheduler     *scheduler
+	janitor       *Janitor
+	diskGuard     *DiskGuard
+	retention     *Retention
+	// maxDuration is the duration of occurrences without an end
+	maxDuration time.Duration
+
+	catchUpPolicy string
+
+	maxConcurrent int
+	overlapPolicy string
+	slots         *slots
 }
 
-func NewVcr(db dbs.Db, runtime runtime.ContainerRuntime, containerConf config.ContainerConfig) (*Vcr, error) {
+type VcrOpts func(*Vcr) error
+
+func NewVcr(db dbs.Db, runtime runtime.ContainerRuntime, containerConf config.ContainerConfig, opts ...VcrOpts) (*Vcr, error) {
 	if db == nil {
 		return nil, errors.New("no db supplied")
 	}
@@ -39,69 +73,523 @@ func NewVcr(db dbs.Db, runtime runtime.ContainerRuntime, containerConf config.Co
 		return nil, errors.New("no runtime supplied")
 	}
 
-	return &Vcr{
+	vcr := &Vcr{
 		db:            db,
+		history:       dbs.NewMemoryHistory(),
 		runtime:       runtime,
 		containerConf: containerConf,
+		location:      time.Local,
+		scheduler:     newScheduler(defaultLeadTime),
+		catchUpPolicy: config.CatchUpPolicyStartLate,
 
 		programmings: map[string]ScheduledRecording{},
 		wg:           &sync.WaitGroup{},
-	}, nil
+	}
+
+	var errs error
+	for _, opt := range opts {
+		if err := opt(vcr); err != nil {
+			errs = multierr.Append(errs, err)
+		}
+	}
+
+	return vcr, errs
 }
 
-func (a *Vcr) ControlLoop(ctx context.Context, wg *sync.WaitGroup) {
-	ticker := time.NewTicker(1 * time.Minute)
+// WithLocation sets the location that times without an explicit offset are interpreted in.
+func WithLocation(loc *time.Location) VcrOpts {
+	return func(v *Vcr) error {
+		if loc == nil {
+			return errors.New("nil location provided")
+		}
+		v.location = loc
+		return nil
+	}
+}
+
+// WithHistory sets the store that runs of recordings are recorded to.
+func WithHistory(history dbs.History) VcrOpts {
+	return func(v *Vcr) error {
+		if history == nil {
+			return errors.New("nil history provided")
+		}
+		v.history = history
+		return nil
+	}
+}
+
+// WithJanitor enables cleaning up containers after recordings ended.
+func WithJanitor(janitor *Janitor) VcrOpts {
+	return func(v *Vcr) error {
+		if janitor == nil {
+			return errors.New("nil janitor provided")
+		}
+		v.janitor = janitor
+		return nil
+	}
+}
 
+// WithConcurrencyLimit limits the amount of simultaneous recordings. Programmings that would exceed the limit are
+// rejected or only warned about, depending on the overlap policy.
+func WithConcurrencyLimit(max int, overlapPolicy string) VcrOpts {
+	return func(v *Vcr) error {
+		if max < 0 {
+			return errors.New("limit of concurrent recordings must not be negative")
+		}
+		if overlapPolicy != config.OverlapPolicyReject && overlapPolicy != config.OverlapPolicyWarn {
+			return fmt.Errorf("unknown overlap policy %q", overlapPolicy)
+		}
+		v.maxConcurrent = max
+		v.overlapPolicy = overlapPolicy
+		if max > 0 {
+			v.slots = newSlots(max)
+		}
+		return nil
+	}
+}
+
+// WithPadding sets the default padding of programmings that don't define their own padding.
+func WithPadding(padding config.Padding) VcrOpts {
+	return func(v *Vcr) error {
+		if padding.Before < 0 || padding.After < 0 {
+			return errors.New("padding must not be negative")
+		}
+		v.scheduler.padding = padding
+		return nil
+	}
+}
+
+// WithMaxDuration sets the duration after which recordings of programmings without an end are stopped.
+func WithMaxDuration(maxDuration time.Duration) VcrOpts {
+	return func(v *Vcr) error {
+		if maxDuration < 0 {
+			return errors.New("max duration must not be negative")
+		}
+		v.maxDuration = maxDuration
+		return nil
+	}
+}
+
+// WithCatchUpPolicy sets whether programmings whose start has been missed are recorded late.
+func WithCatchUpPolicy(policy string) VcrOpts {
+	return func(v *Vcr) error {
+		if policy != config.CatchUpPolicyStartLate && policy != config.CatchUpPolicySkip {
+			return fmt.Errorf("unknown catch up policy %q", policy)
+		}
+		v.catchUpPolicy = policy
+		return nil
+	}
+}
+
+// WithDiskGuard enables checking the free space before and while recording.
+func WithDiskGuard(guard *DiskGuard) VcrOpts {
+	return func(v *Vcr) error {
+		if guard == nil {
+			return errors.New("nil disk guard provided")
+		}
+		v.diskGuard = guard
+		return nil
+	}
+}
+
+// WithRetention enables listing the recorded files that are removed by the retention.
+func WithRetention(retention *Retention) VcrOpts {
+	return func(v *Vcr) error {
+		if retention == nil {
+			return errors.New("nil retention provided")
+		}
+		v.retention = retention
+		return nil
+	}
+}
+
+// WithLeadTime sets how long before the start of a programming its recording is created.
+func WithLeadTime(leadTime time.Duration) VcrOpts {
+	return func(v *Vcr) error {
+		if leadTime <= 0 {
+			return errors.New("lead time must be positive")
+		}
+		v.scheduler.leadTime = leadTime
+		return nil
+	}
+}
+
+func (a *Vcr) ControlLoop(ctx context.Context, wg *sync.WaitGroup) {
 	wg.Add(1)
+	var timer *time.Timer
 	defer func() {
 		log.Info().Msgf("vcr: defer wg.Done()")
 		wg.Done()
-		ticker.Stop()
+		if timer != nil {
+			timer.Stop()
+		}
 	}()
 
+	a.scheduler.notifyAll()
 	for {
+		var timerChan <-chan time.Time
+		if wait, ok := a.scheduler.next(time.Now()); ok {
+			timer = time.NewTimer(wait)
+			timerChan = timer.C
+		}
+
 		select {
 		case <-ctx.Done():
 			log.Info().Msgf("app: received done, sending signals to scheduled runs")
+			a.mutex.Lock()
 			for _, recording := range a.programmings {
 				recording.done <- true
 				close(recording.done)
 			}
+			a.programmings = map[string]ScheduledRecording{}
+			a.mutex.Unlock()
+			// recorders stop their containers and store their state on shutdown, the process must not exit before
+			log.Info().Msgf("app: waiting for recordings to stop")
+			a.wg.Wait()
 			log.Info().Msgf("Closed")
 			return
+		case <-a.scheduler.wakeup:
+			a.applyChanges()
+		case <-timerChan:
+			for _, entry := range a.scheduler.popDue(time.Now()) {
+				a.scheduleOccurrence(entry.programming, entry.occurrence)
+			}
+		}
+
+		if timer != nil {
+			timer.Stop()
+			timer = nil
+		}
+	}
+}
+
+// MonitorDiskSpace periodically checks the free space while recording. If it runs out, the running recording with the
+// lowest priority is stopped.
+func (a *Vcr) MonitorDiskSpace(ctx context.Context, wg *sync.WaitGroup) {
+	if a.diskGuard == nil || !a.diskGuard.enabled() {
+		return
+	}
+
+	wg.Add(1)
+	defer wg.Done()
+
+	ticker := time.NewTicker(a.diskGuard.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
 		case <-ticker.C:
-			programmings, err := a.db.List()
-			if err != nil {
-				log.Error().Err(err).Msg("could not get a list of programmings")
+			if err := a.diskGuard.check(); err != nil {
+				a.stopLowestPriority(err.Error())
+			}
+		}
+	}
+}
+
+// stopLowestPriority stops the running recording with the lowest priority.
+func (a *Vcr) stopLowestPriority(reason string) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	var victim *Recorder
+	for _, scheduled := range a.programmings {
+		recording := scheduled.recording
+		if !recording.IsRecording() || recording.isCancelled() {
+			continue
+		}
+		if victim == nil || recording.Priority() < victim.Priority() {
+			victim = recording
+		}
+	}
+
+	if victim == nil {
+		return
+	}
+
+	log.Warn().Msgf("Stopping recording of programming '%s': %s", victim.programming.Name, reason)
+	victim.cancelWithMessage(reason)
+}
+
+// applyChanges updates the scheduler with the programmings that have changed since the last call.
+func (a *Vcr) applyChanges() {
+	names, all := a.scheduler.drain()
+	now := time.Now()
+
+	if all {
+		programmings, err := a.db.List()
+		if err != nil {
+			log.Error().Err(err).Msg("could not get a list of programmings, retrying")
+			time.AfterFunc(reloadRetryInterval, a.scheduler.notifyAll)
+			return
+		}
+
+		a.syncRecordings(programmings)
+		a.scheduler.reset()
+		for _, programming := range programmings {
+			a.catchUp(programming, now)
+			a.scheduler.plan(programming, now)
+		}
+		return
+	}
+
+	for _, name := range names {
+		programming, err := a.db.Find(name)
+		if err != nil {
+			if errors.Is(err, dbs.ErrNotFound) {
+				a.scheduler.remove(name)
+				continue
+			}
+			log.Error().Err(err).Msgf("could not get programming '%s', retrying", name)
+			time.AfterFunc(reloadRetryInterval, func() {
+				a.scheduler.notify(name)
+			})
+			continue
+		}
+		a.catchUp(*programming, now)
+		a.scheduler.plan(*programming, now)
+	}
+}
+
+// catchUp handles the latest occurrence of the programming that has started but has not been recorded, e.g. because
+// vcr was not running at its start. Depending on the catch up policy, the occurrence is recorded late if it has not
+// ended yet, otherwise it's marked as missed.
+func (a *Vcr) catchUp(programming config.Programming, now time.Time) {
+	since := now.Add(-catchUpLookback)
+	if programming.Status != nil {
+		if handled := programming.Status.HandledSince(); handled.After(since) {
+			since = handled
+		}
+	}
+
+	padding := programming.PaddingOrDefault(a.scheduler.padding)
+	occurrence, ok := programming.LastOccurrence(since.Add(padding.Before), now.Add(padding.Before))
+	if !ok {
+		return
+	}
+
+	a.mutex.Lock()
+	_, scheduled := a.programmings[programming.Name]
+	a.mutex.Unlock()
+	if scheduled {
+		return
+	}
+
+	padded := padding.Apply(a.bounded(occurrence))
+	ended := padded.Until != nil && !padded.Until.After(now)
+	if !ended && a.catchUpPolicy == config.CatchUpPolicyStartLate {
+		log.Warn().Msgf("Start of programming '%s' at %v has been missed, starting late", programming.Name, occurrence.Start)
+		a.scheduleOccurrence(programming, occurrence)
+		return
+	}
+
+	a.missed(programming, occurrence)
+}
+
+// transition persists the new state of the recording of the programming.
+func (a *Vcr) transition(name string, state config.RecordingState, message string) {
+	err := a.db.Update(name, func(p *config.Programming) error {
+		if p.Status == nil {
+			p.Status = &config.RecordingStatus{}
+		}
+		return p.Status.Transition(state, message, time.Now())
+	})
+	if err != nil && !errors.Is(err, dbs.ErrNotFound) {
+		log.Error().Err(err).Msgf("could not update state of programming '%s' to %s", name, state)
+	}
+}
+
+// missed marks the occurrence of the programming as missed and records it in the history.
+func (a *Vcr) missed(programming config.Programming, occurrence config.Occurrence) {
+	message := fmt.Sprintf("missed start at %s", occurrence.Start.Format(time.RFC3339))
+	log.Warn().Msgf("Recording of programming '%s' %s", programming.Name, message)
+
+	a.transition(programming.Name, config.StateMissed, message)
+
+	run := config.RecordingRun{
+		Name:  programming.Name,
+		Start: occurrence.Start,
+		Error: message,
+	}
+	if err := a.history.Record(run); err != nil {
+		log.Error().Err(err).Msgf("could not record missed run of programming '%s'", programming.Name)
+	}
+}
+
+// Reload makes the vcr pick up changes to programmings that were made outside of vcr.
+func (a *Vcr) Reload() {
+	a.scheduler.notifyAll()
+}
+
+func (a *Vcr) scheduleOccurrence(programming config.Programming, occurrence config.Occurrence) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	_, ok := a.programmings[programming.Name]
+	if ok {
+		return
+	}
+
+	log.Info().Msgf("Creating new recording for programming '%s' at %v", programming.Name, occurrence.Start)
+	recording, err := a.newRecording(programming, occurrence)
+	if err != nil {
+		log.Error().Err(err).Msg("could not create recording")
+		return
+	}
+
+	a.start(programming, recording, occurrence)
+}
+
+// AdoptContainers takes over the running containers of a previous vcr process, so their recordings are still stopped
+// at the end of the programming. Containers that can't be adopted, e.g. because their programming has been deleted,
+// are stopped. Containers whose programming could not be read are returned as error, the call can be repeated to adopt
+// them. Must be called before the control loop is started.
+func (a *Vcr) AdoptContainers(ctx context.Context) error {
+	containers, err := a.runtime.ListContainers(ctx)
+	if err != nil {
+		return err
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	var errs error
+	for _, info := range containers {
+		if !info.Running {
+			continue
+		}
+
+		programming, err := a.db.Find(info.Name)
+		if errors.Is(err, dbs.ErrNotFound) {
+			// nothing would ever stop the container of an unknown programming
+			log.Warn().Msgf("Stopping container %s, programming '%s' does not exist", info.Id, info.Name)
+			a.stopContainer(ctx, info.Id)
+			continue
+		}
+		if err != nil {
+			errs = multierr.Append(errs, fmt.Errorf("could not get programming '%s' of container %s: %w", info.Name, info.Id, err))
+			continue
+		}
+
+		if scheduled, ok := a.programmings[programming.Name]; ok {
+			if scheduled.containerId == info.Id {
+				// adopted by a previous call
 				continue
 			}
+			log.Warn().Msgf("Stopping container %s, programming '%s' already has a recording", info.Id, info.Name)
+			a.stopContainer(ctx, info.Id)
+			continue
+		}
+
+		// containers are started before the programming if it's padded
+		padding := programming.PaddingOrDefault(a.scheduler.padding)
+		occurrence, ok := programming.OccurrenceContaining(info.Created.Add(padding.Before))
+		if !ok {
+			occurrence = programming.OccurrenceAt(info.Created)
+		}
+
+		recording, err := a.newRecording(*programming, occurrence)
+		if err != nil {
+			errs = multierr.Append(errs, fmt.Errorf("could not create recording of container %s: %w", info.Id, err))
+			continue
+		}
+		recording.Adopt(info)
+		a.start(*programming, recording, occurrence)
+
+		scheduled := a.programmings[programming.Name]
+		scheduled.containerId = info.Id
+		a.programmings[programming.Name] = scheduled
+	}
+
+	return errs
+}
+
+// stopContainer kills a running container that can't be adopted.
+func (a *Vcr) stopContainer(ctx context.Context, id string) {
+	if err := a.runtime.KillContainer(ctx, id, runtime.SignalKill); err != nil && !errors.Is(err, runtime.ErrContainerNotFound) {
+		log.Error().Err(err).Msgf("could not stop container %s", id)
+	}
+}
 
-			for _, programming := range programmings {
-				if programming.IsUpcoming() && programming.Date.Sub(time.Now()) < 5*time.Minute {
-					_, ok := a.programmings[programming.Name]
-					if ok {
-						continue
-					}
-					log.Info().Msgf("Creating new recording for programming '%s'", programming.Name)
-					recording, err := NewRecording(a.runtime, programming, a.containerConf, a.wg)
-					if err != nil {
-						log.Error().Err(err).Msg("could not create recording")
-					}
-
-					s := ScheduledRecording{
-						recording: recording,
-						done:      make(chan bool, 1),
-					}
-					a.programmings[programming.Name] = s
-					go func() {
-						if err := recording.Schedule(s.done); err != nil {
-							log.Error().Err(err).Msg("scheduling failed")
-						}
-					}()
-				}
+func (a *Vcr) newRecording(programming config.Programming, occurrence config.Occurrence) (*Recorder, error) {
+	recording, err := NewRecording(a.db, a.runtime, a.forOccurrence(programming, occurrence), a.containerConf, a.wg)
+	if err != nil {
+		return nil, err
+	}
+	recording.slots = a.slots
+	recording.diskGuard = a.diskGuard
+	recording.startLate = a.catchUpPolicy == config.CatchUpPolicyStartLate
+	return recording, nil
+}
+
+// forOccurrence returns the programming of a single occurrence, programmings without padding get the default padding.
+func (a *Vcr) forOccurrence(programming config.Programming, occurrence config.Occurrence) config.Programming {
+	ret := programming.ForOccurrence(a.bounded(occurrence))
+	padding := programming.PaddingOrDefault(a.scheduler.padding)
+	ret.Padding = &padding
+	return ret
+}
+
+// bounded returns the occurrence with an end after the max duration if it has no end.
+func (a *Vcr) bounded(occurrence config.Occurrence) config.Occurrence {
+	if a.maxDuration <= 0 || (occurrence.Until != nil && !occurrence.Until.IsZero()) {
+		return occurrence
+	}
+	until := occurrence.Start.Add(a.maxDuration)
+	occurrence.Until = &until
+	return occurrence
+}
+
+// start runs the recording in the background. Must be called while holding the mutex.
+func (a *Vcr) start(programming config.Programming, recording *Recorder, occurrence config.Occurrence) {
+	name := programming.Name
+	s := ScheduledRecording{
+		recording:   recording,
+		done:        make(chan bool, 1),
+		occurrence:  occurrence,
+		programming: programming,
+	}
+	a.programmings[name] = s
+	// added before the goroutine is started, so the control loop cannot stop waiting before the recording is done
+	a.wg.Add(1)
+	go func() {
+		defer a.wg.Done()
+		if err := recording.Schedule(s.done); err != nil {
+			log.Error().Err(err).Msg("scheduling failed")
+		}
+		for _, run := range recording.Runs() {
+			if err := a.history.Record(run); err != nil {
+				log.Error().Err(err).Msgf("could not record run of programming '%s'", name)
 			}
 		}
+		a.cleanup(name)
+		a.finished(name, recording)
+	}()
+}
+
+func (a *Vcr) cleanup(name string) {
+	if a.janitor == nil {
+		return
 	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	if err := a.janitor.Cleanup(ctx, name); err != nil {
+		log.Error().Err(err).Msgf("could not clean up containers of programming '%s'", name)
+	}
+}
+
+// finished removes a recording after it's been completed so the next occurrence of the programming can be scheduled.
+func (a *Vcr) finished(name string, recording *Recorder) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	scheduled, ok := a.programmings[name]
+	if ok && scheduled.recording == recording {
+		delete(a.programmings, name)
+	}
+	a.scheduler.notify(name)
 }
 
 func (a *Vcr) AddProgramming(req ports.AddProgrammingRequest) error {
@@ -109,12 +597,202 @@ func (a *Vcr) AddProgramming(req ports.AddProgrammingRequest) error {
 		return fmt.Errorf("%w: %v", ErrValidationError, err)
 	}
 
-	p, err := req.ToProgramming()
+	p, err := req.ToProgramming(a.location)
 	if err != nil {
+		return fmt.Errorf("%w: %v", ErrValidationError, err)
+	}
+
+	if !p.IsUpcoming() {
+		return fmt.Errorf("%w: programming has no upcoming recording", ErrValidationError)
+	}
+
+	// replacing a programming would leave its recording running with the previous programming, see UpdateProgramming
+	if _, err := a.db.Find(p.Name); err == nil {
+		return fmt.Errorf("%w: '%s'", ErrAlreadyExists, p.Name)
+	} else if !errors.Is(err, dbs.ErrNotFound) {
+		return err
+	}
+
+	if err := a.checkOverlaps(p); err != nil {
 		return err
 	}
 
-	return a.db.Add(p)
+	p.Status = &config.RecordingStatus{}
+	if err := p.Status.Transition(config.StateScheduled, "programming added", time.Now()); err != nil {
+		return err
+	}
+
+	if err := a.db.Add(p); err != nil {
+		return err
+	}
+
+	a.scheduler.notify(p.Name)
+	return nil
+}
+
+// UpdateProgramming replaces an existing programming. A pending recording is rescheduled, a running recording keeps
+// running until the updated end.
+func (a *Vcr) UpdateProgramming(req ports.UpdateProgrammingRequest) error {
+	if err := config.Validate(req); err != nil {
+		return fmt.Errorf("%w: %v", ErrValidationError, err)
+	}
+
+	p, err := req.ToProgramming(a.location)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrValidationError, err)
+	}
+
+	if err := a.checkOverlaps(p); err != nil {
+		return err
+	}
+
+	err = a.db.Update(p.Name, func(programming *config.Programming) error {
+		p.Status = programming.Status
+		*programming = p
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, dbs.ErrNotFound) {
+			return ErrNotFound
+		}
+		return err
+	}
+
+	a.reschedule(p)
+	a.scheduler.notify(p.Name)
+	return nil
+}
+
+// checkOverlaps returns ErrConflict if the programming would exceed the limit of concurrent recordings. Only
+// programmings with at least the same priority are considered, all others are preempted.
+func (a *Vcr) checkOverlaps(programming config.Programming) error {
+	if a.maxConcurrent <= 0 {
+		return nil
+	}
+
+	programmings, err := a.db.List()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	horizon := now.Add(overlapHorizon)
+	var others []config.Occurrence
+	for _, other := range programmings {
+		if other.Name == programming.Name || other.Priority < programming.Priority {
+			continue
+		}
+		if other.Recurrence == nil && other.Status != nil && other.Status.State.IsTerminal() {
+			continue
+		}
+		padding := other.PaddingOrDefault(a.scheduler.padding)
+		for _, occurrence := range occurrencesBetween(other, now, horizon) {
+			others = append(others, padding.Apply(a.bounded(occurrence)))
+		}
+	}
+
+	padding := programming.PaddingOrDefault(a.scheduler.padding)
+	for _, occurrence := range occurrencesBetween(programming, now, horizon) {
+		occurrence = padding.Apply(a.bounded(occurrence))
+		if peakOverlap(occurrence, others, horizon) < a.maxConcurrent {
+			continue
+		}
+
+		err := fmt.Errorf("%w: more than %d recordings at %s", ErrConflict, a.maxConcurrent, occurrence.Start.Format(time.RFC3339))
+		if a.overlapPolicy == config.OverlapPolicyWarn {
+			log.Warn().Err(err).Msgf("Programming '%s' overlaps with other programmings", programming.Name)
+			return nil
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (a *Vcr) reschedule(programming config.Programming) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	scheduled, ok := a.programmings[programming.Name]
+	if !ok {
+		return
+	}
+
+	if scheduled.recording.IsRecording() {
+		occurrence := programming.OccurrenceAt(scheduled.occurrence.Start)
+		scheduled.recording.Reschedule(a.forOccurrence(programming, occurrence))
+		scheduled.occurrence = occurrence
+		scheduled.programming = programming
+		a.programmings[programming.Name] = scheduled
+		return
+	}
+
+	occurrence, ok := programming.NextOccurrence(time.Now())
+	if !ok {
+		scheduled.recording.Cancel()
+		delete(a.programmings, programming.Name)
+		return
+	}
+
+	if time.Now().Before(a.scheduler.dueAt(programming, occurrence)) {
+		// the scheduler creates a new recording when it's due
+		scheduled.recording.Discard()
+		delete(a.programmings, programming.Name)
+		a.transition(programming.Name, config.StateScheduled, fmt.Sprintf("rescheduled for %s", occurrence.Start.Format(time.RFC3339)))
+		return
+	}
+
+	log.Info().Msgf("Rescheduling recording for programming '%s' to %v", programming.Name, occurrence.Start)
+	scheduled.recording.Reschedule(a.forOccurrence(programming, occurrence))
+	scheduled.occurrence = occurrence
+	scheduled.programming = programming
+	a.programmings[programming.Name] = scheduled
+}
+
+// programmingChanged returns whether the programmings differ in anything but their status.
+func programmingChanged(a, b config.Programming) bool {
+	if !a.Date.Equal(b.Date) || (a.Until == nil) != (b.Until == nil) {
+		return true
+	}
+	if a.Until != nil && !a.Until.Equal(*b.Until) {
+		return true
+	}
+
+	// times are compared above, as their location differs depending on where they have been parsed
+	a.Date, b.Date = time.Time{}, time.Time{}
+	a.Until, b.Until = nil, nil
+	a.Status, b.Status = nil, nil
+	return !reflect.DeepEqual(a, b)
+}
+
+// syncRecordings applies changes that were made outside of vcr, e.g. by editing the programmings file, to existing
+// recordings like DeleteProgramming and UpdateProgramming do. Recordings of removed programmings are cancelled,
+// recordings of changed programmings are rescheduled.
+func (a *Vcr) syncRecordings(programmings []config.Programming) {
+	current := make(map[string]config.Programming, len(programmings))
+	for _, programming := range programmings {
+		current[programming.Name] = programming
+	}
+
+	var changed []config.Programming
+	a.mutex.Lock()
+	for name, scheduled := range a.programmings {
+		programming, ok := current[name]
+		if !ok {
+			log.Info().Msgf("Cancelling recording for removed programming '%s'", name)
+			scheduled.recording.Cancel()
+			delete(a.programmings, name)
+			continue
+		}
+		if programmingChanged(scheduled.programming, programming) {
+			changed = append(changed, programming)
+		}
+	}
+	a.mutex.Unlock()
+
+	for _, programming := range changed {
+		a.reschedule(programming)
+	}
 }
 
 func (a *Vcr) GetProgrammings(req ports.GetProgrammingRequest) (*config.Programming, error) {
@@ -134,5 +812,39 @@ func (a *Vcr) DeleteProgramming(req ports.DeleteProgrammingRequest) error {
 		return fmt.Errorf("%w: %v", ErrValidationError, err)
 	}
 
-	return a.db.Delete(req.Name)
+	if err := a.db.Delete(req.Name); err != nil {
+		return err
+	}
+
+	a.mutex.Lock()
+	if scheduled, ok := a.programmings[req.Name]; ok {
+		log.Info().Msgf("Cancelling recording for deleted programming '%s'", req.Name)
+		scheduled.recording.Cancel()
+		delete(a.programmings, req.Name)
+	}
+	a.mutex.Unlock()
+
+	a.scheduler.notify(req.Name)
+	return nil
+}
+
+func (a *Vcr) GetHistory(req ports.HistoryRequest) ([]config.RecordingRun, error) {
+	if err := config.Validate(req); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrValidationError, err)
+	}
+
+	filter, err := req.ToFilter(a.location)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrValidationError, err)
+	}
+
+	return a.history.Query(filter)
+}
+
+// GetRetentionCandidates returns the recorded files that are removed by the retention.
+func (a *Vcr) GetRetentionCandidates() ([]RetentionCandidate, error) {
+	if a.retention == nil {
+		return nil, nil
+	}
+	return a.retention.Plan()
 }
@@ -0,0 +1,292 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+	"vcr/internal/config"
+	"vcr/internal/dbs"
+)
+
+// waitFor polls the condition until it's met or the timeout is reached.
+func waitFor(t *testing.T, timeout time.Duration, condition func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met before timeout")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func runningProgramming(name string) config.Programming {
+	start := time.Now().Add(-time.Minute)
+	until := start.Add(time.Hour)
+	return config.Programming{Name: name, Url: "https://example.com/" + name, Date: start, Until: &until}
+}
+
+func newTestVcr(t *testing.T, db dbs.Db, fake *fakeRuntime, opts ...VcrOpts) *Vcr {
+	t.Helper()
+	conf := config.ContainerConfig{Image: "image", StopSignal: "SIGINT", StopGracePeriod: time.Second}
+	vcr, err := NewVcr(db, fake, conf, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return vcr
+}
+
+func TestVcr_ControlLoopWaitsForRecordings(t *testing.T) {
+	db := dbs.NewMemoryDb()
+	if err := db.Add(runningProgramming("news")); err != nil {
+		t.Fatal(err)
+	}
+	fake := newFakeRuntime()
+	history := dbs.NewMemoryHistory()
+	vcr := newTestVcr(t, db, fake, WithHistory(history))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	returned := make(chan struct{})
+	go func() {
+		vcr.ControlLoop(ctx, &sync.WaitGroup{})
+		close(returned)
+	}()
+	waitFor(t, 5*time.Second, func() bool { return len(fake.running()) == 1 })
+
+	cancel()
+	<-returned
+
+	if running := fake.running(); len(running) != 0 {
+		t.Errorf("running containers after ControlLoop returned = %v, want none", running)
+	}
+	runs, err := history.Query(dbs.HistoryFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 1 {
+		t.Errorf("recorded runs after ControlLoop returned = %d, want 1", len(runs))
+	}
+}
+
+func TestVcr_AdoptContainers(t *testing.T) {
+	db := dbs.NewMemoryDb()
+	if err := db.Add(runningProgramming("news")); err != nil {
+		t.Fatal(err)
+	}
+	fake := newFakeRuntime()
+	fake.add("adoptable", "news", time.Now().Add(-time.Minute))
+	fake.add("duplicate", "news", time.Now().Add(-time.Minute))
+	fake.add("unknown", "deleted", time.Now().Add(-time.Minute))
+	vcr := newTestVcr(t, db, fake)
+
+	if err := vcr.AdoptContainers(context.Background()); err != nil {
+		t.Fatalf("AdoptContainers() error = %v", err)
+	}
+
+	running := fake.running()
+	if len(running) != 1 || (running[0] != "adoptable" && running[0] != "duplicate") {
+		t.Errorf("running containers = %v, want the adopted container only", running)
+	}
+	for _, call := range fake.killCalls() {
+		if call.id == running[0] {
+			t.Errorf("adopted container %s has been killed", call.id)
+		}
+	}
+
+	// the adopted recording is stopped on shutdown
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	vcr.ControlLoop(ctx, &sync.WaitGroup{})
+	if running := fake.running(); len(running) != 0 {
+		t.Errorf("running containers after shutdown = %v, want none", running)
+	}
+}
+
+func TestVcr_ResumeAfterShutdown(t *testing.T) {
+	db := dbs.NewMemoryDb()
+	if err := db.Add(runningProgramming("news")); err != nil {
+		t.Fatal(err)
+	}
+	fake := newFakeRuntime()
+	history := dbs.NewMemoryHistory()
+
+	for i := 1; i <= 2; i++ {
+		vcr := newTestVcr(t, db, fake, WithHistory(history))
+		ctx, cancel := context.WithCancel(context.Background())
+		returned := make(chan struct{})
+		go func() {
+			vcr.ControlLoop(ctx, &sync.WaitGroup{})
+			close(returned)
+		}()
+		// the recording is restarted after the shutdown
+		waitFor(t, 5*time.Second, func() bool { return len(fake.running()) == 1 })
+		cancel()
+		<-returned
+
+		p, err := db.Find("news")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.Status == nil || p.Status.State != config.StateInterrupted {
+			t.Fatalf("status after shutdown %d = %+v, want interrupted", i, p.Status)
+		}
+	}
+
+	if calls := len(fake.killCalls()); calls != 2 {
+		t.Errorf("containers stopped = %d, want 2", calls)
+	}
+
+	// the resumed recording continues with the next attempt instead of overwriting the output of the first one
+	runs, err := history.Query(dbs.HistoryFilter{Name: "news"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 2 || runs[0].Attempt != 1 || runs[1].Attempt != 2 {
+		t.Errorf("runs = %+v, want attempts 1 and 2", runs)
+	}
+}
+
+func TestVcr_RetryStartUntilEnd(t *testing.T) {
+	tests := []struct {
+		policy    string
+		wantState config.RecordingState
+	}{
+		{policy: config.CatchUpPolicyStartLate, wantState: config.StateRecording},
+		{policy: config.CatchUpPolicySkip, wantState: config.StateFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			p := runningProgramming("news")
+			p.Date = time.Now().Add(100 * time.Millisecond)
+			// the retry policy alone does not allow another attempt
+			p.Retry = &config.RetryPolicy{MaxAttempts: 1, InitialBackoff: 20 * time.Millisecond, MaxBackoff: 20 * time.Millisecond}
+			db := dbs.NewMemoryDb()
+			if err := db.Add(p); err != nil {
+				t.Fatal(err)
+			}
+			fake := newFakeRuntime()
+			fake.runErr = errors.New("cannot connect to the container runtime")
+			fake.failingRuns = 3
+			vcr := newTestVcr(t, db, fake, WithCatchUpPolicy(tt.policy))
+
+			ctx, cancel := context.WithCancel(context.Background())
+			returned := make(chan struct{})
+			go func() {
+				vcr.ControlLoop(ctx, &sync.WaitGroup{})
+				close(returned)
+			}()
+			defer func() {
+				cancel()
+				<-returned
+			}()
+
+			waitFor(t, 5*time.Second, func() bool {
+				found, err := db.Find("news")
+				return err == nil && found.Status != nil && found.Status.State == tt.wantState
+			})
+		})
+	}
+}
+
+// flakyDb fails to find the given programmings once.
+type flakyDb struct {
+	dbs.Db
+	mutex sync.Mutex
+	fail  map[string]bool
+}
+
+func (d *flakyDb) Find(name string) (*config.Programming, error) {
+	d.mutex.Lock()
+	fail := d.fail[name]
+	delete(d.fail, name)
+	d.mutex.Unlock()
+
+	if fail {
+		return nil, errors.New("database is locked")
+	}
+	return d.Db.Find(name)
+}
+
+func TestVcr_AdoptContainersRetry(t *testing.T) {
+	db := &flakyDb{Db: dbs.NewMemoryDb(), fail: map[string]bool{"sports": true}}
+	for _, name := range []string{"news", "sports"} {
+		if err := db.Add(runningProgramming(name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fake := newFakeRuntime()
+	fake.add("news-container", "news", time.Now().Add(-time.Minute))
+	fake.add("sports-container", "sports", time.Now().Add(-time.Minute))
+	vcr := newTestVcr(t, db, fake)
+
+	if err := vcr.AdoptContainers(context.Background()); err == nil {
+		t.Fatal("AdoptContainers() error = nil, want error of the failed programming")
+	}
+	if err := vcr.AdoptContainers(context.Background()); err != nil {
+		t.Fatalf("AdoptContainers() retry error = %v", err)
+	}
+
+	if kills := fake.killCalls(); len(kills) != 0 {
+		t.Errorf("kill calls = %+v, want both containers to be adopted", kills)
+	}
+	vcr.mutex.Lock()
+	adopted := map[string]string{}
+	for name, scheduled := range vcr.programmings {
+		adopted[name] = scheduled.containerId
+	}
+	vcr.mutex.Unlock()
+	if adopted["news"] != "news-container" || adopted["sports"] != "sports-container" {
+		t.Errorf("adopted containers = %v", adopted)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	vcr.ControlLoop(ctx, &sync.WaitGroup{})
+}
+
+func TestVcr_ReloadUpdatesPendingRecording(t *testing.T) {
+	p := runningProgramming("news")
+	p.Date = time.Now().Add(500 * time.Millisecond)
+	db := dbs.NewMemoryDb()
+	if err := db.Add(p); err != nil {
+		t.Fatal(err)
+	}
+	fake := newFakeRuntime()
+	// the recording is created right away and waits for its start
+	vcr := newTestVcr(t, db, fake, WithLeadTime(time.Hour))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	returned := make(chan struct{})
+	go func() {
+		vcr.ControlLoop(ctx, &sync.WaitGroup{})
+		close(returned)
+	}()
+	defer func() {
+		cancel()
+		<-returned
+	}()
+	waitFor(t, 5*time.Second, func() bool {
+		vcr.mutex.Lock()
+		defer vcr.mutex.Unlock()
+		_, ok := vcr.programmings["news"]
+		return ok
+	})
+
+	// only the url changes, e.g. by editing the programmings file
+	err := db.Update("news", func(p *config.Programming) error {
+		p.Url = "https://example.org/news"
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	vcr.Reload()
+
+	waitFor(t, 5*time.Second, func() bool { return len(fake.running()) == 1 })
+	args := fake.argsOf(fake.running()[0])
+	if len(args) == 0 || args[len(args)-1] != "https://example.org/news" {
+		t.Errorf("container args = %v, want the updated url", args)
+	}
+}
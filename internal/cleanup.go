@@ -0,0 +1,105 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+	"vcr/internal/config"
+	"vcr/internal/runtime"
+
+	"github.com/rs/zerolog/log"
+	"go.uber.org/multierr"
+)
+
+// Janitor removes stopped containers according to the configured cleanup policy.
+type Janitor struct {
+	runtime runtime.ContainerRuntime
+	conf    config.CleanupConfig
+}
+
+func NewJanitor(runtime runtime.ContainerRuntime, conf config.CleanupConfig) (*Janitor, error) {
+	if runtime == nil {
+		return nil, errors.New("no runtime supplied")
+	}
+
+	return &Janitor{
+		runtime: runtime,
+		conf:    conf,
+	}, nil
+}
+
+// Cleanup applies the policy to the stopped containers of the given programming.
+func (j *Janitor) Cleanup(ctx context.Context, name string) error {
+	return j.cleanup(ctx, func(info runtime.ContainerInfo) bool {
+		return info.Name == name
+	})
+}
+
+// Sweep applies the policy to all stopped containers created by vcr.
+func (j *Janitor) Sweep(ctx context.Context) error {
+	return j.cleanup(ctx, func(info runtime.ContainerInfo) bool {
+		return true
+	})
+}
+
+func (j *Janitor) cleanup(ctx context.Context, include func(runtime.ContainerInfo) bool) error {
+	if j.conf.Policy == config.CleanupPolicyNone {
+		return nil
+	}
+
+	containers, err := j.runtime.ListContainers(ctx)
+	if err != nil {
+		return err
+	}
+
+	var stopped []runtime.ContainerInfo
+	for _, info := range containers {
+		if !info.Running && include(info) {
+			stopped = append(stopped, info)
+		}
+	}
+
+	var errs error
+	for _, info := range j.selectForRemoval(stopped, time.Now()) {
+		log.Info().Msgf("Removing container %s of programming '%s'", info.Id, info.Name)
+		if err := j.runtime.DeleteContainer(ctx, info.Id); err != nil {
+			errs = multierr.Append(errs, err)
+		}
+	}
+	return errs
+}
+
+func (j *Janitor) selectForRemoval(stopped []runtime.ContainerInfo, now time.Time) []runtime.ContainerInfo {
+	switch j.conf.Policy {
+	case config.CleanupPolicyRemove:
+		return stopped
+	case config.CleanupPolicyKeepFor:
+		var ret []runtime.ContainerInfo
+		for _, info := range stopped {
+			if !info.Finished.IsZero() && now.Sub(info.Finished) > j.conf.KeepFor {
+				ret = append(ret, info)
+			}
+		}
+		return ret
+	case config.CleanupPolicyKeepLast:
+		byName := map[string][]runtime.ContainerInfo{}
+		for _, info := range stopped {
+			byName[info.Name] = append(byName[info.Name], info)
+		}
+
+		var ret []runtime.ContainerInfo
+		for _, containers := range byName {
+			if len(containers) <= j.conf.KeepLast {
+				continue
+			}
+			sort.Slice(containers, func(i, k int) bool {
+				return containers[i].Created.After(containers[k].Created)
+			})
+			ret = append(ret, containers[j.conf.KeepLast:]...)
+		}
+		return ret
+	default:
+		return nil
+	}
+}
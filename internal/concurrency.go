@@ -0,0 +1,191 @@
+package internal
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+	"vcr/internal/config"
+)
+
+var errConcurrencyLimit = errors.New("maximum of concurrent recordings reached")
+
+// errSlotAborted is returned if waiting for a slot has been aborted because the recording has been cancelled or vcr
+// shuts down.
+var errSlotAborted = errors.New("waiting for a slot aborted")
+
+const (
+	// overlapHorizon limits how far into the future occurrences of programmings are checked for overlaps.
+	overlapHorizon = 30 * 24 * time.Hour
+	// maxCheckedOccurrences limits the amount of occurrences per programming that are checked for overlaps.
+	maxCheckedOccurrences = 1000
+)
+
+// slots limits the amount of recordings that run simultaneously. If all slots are taken, a recording preempts the
+// running recording with the lowest priority, given its own priority is higher. The slot of the preempted recording is
+// handed over once it has stopped, so the limit also holds while it's stopping.
+type slots struct {
+	max     int
+	mutex   sync.Mutex
+	running map[*Recorder]struct{}
+	// preempted maps recordings that are being stopped to the recording that takes over their slot
+	preempted map[*Recorder]*slotWaiter
+}
+
+// slotWaiter is a recording waiting for the slot of a preempted recording, granted is closed once it has the slot.
+type slotWaiter struct {
+	recording *Recorder
+	granted   chan struct{}
+}
+
+func newSlots(max int) *slots {
+	return &slots{
+		max:       max,
+		running:   map[*Recorder]struct{}{},
+		preempted: map[*Recorder]*slotWaiter{},
+	}
+}
+
+// acquire takes a slot for the recording. Acquiring a slot for a recording that already holds one is a no-op. If a
+// recording is preempted, acquire blocks until it has stopped. Waiting is aborted with errSlotAborted if the recording
+// is cancelled or done receives.
+func (s *slots) acquire(recording *Recorder, done <-chan bool) error {
+	s.mutex.Lock()
+
+	if _, ok := s.running[recording]; ok || s.max <= 0 || len(s.running) < s.max {
+		s.running[recording] = struct{}{}
+		s.mutex.Unlock()
+		return nil
+	}
+
+	var victim *Recorder
+	for running := range s.running {
+		if _, ok := s.preempted[running]; ok {
+			// its slot has already been promised to another recording
+			continue
+		}
+		if running.Priority() >= recording.Priority() {
+			continue
+		}
+		if victim == nil || running.Priority() < victim.Priority() {
+			victim = running
+		}
+	}
+	if victim == nil {
+		s.mutex.Unlock()
+		return errConcurrencyLimit
+	}
+
+	waiter := &slotWaiter{recording: recording, granted: make(chan struct{})}
+	s.preempted[victim] = waiter
+	s.mutex.Unlock()
+	victim.Preempt(recording.programming.Name)
+
+	select {
+	case <-waiter.granted:
+		return nil
+	case <-recording.cancel:
+	case <-done:
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.preempted[victim] == waiter {
+		delete(s.preempted, victim)
+	}
+	// the slot may have been handed over in the meantime
+	delete(s.running, recording)
+	return errSlotAborted
+}
+
+// hold takes a slot for the recording regardless of the limit, e.g. for a container that's already running.
+func (s *slots) hold(recording *Recorder) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.running[recording] = struct{}{}
+}
+
+// release frees the slot of the recording. The slot of a preempted recording is handed over to the recording that
+// preempted it.
+func (s *slots) release(recording *Recorder) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, ok := s.running[recording]; !ok {
+		return
+	}
+	delete(s.running, recording)
+	if waiter, ok := s.preempted[recording]; ok {
+		delete(s.preempted, recording)
+		s.running[waiter.recording] = struct{}{}
+		close(waiter.granted)
+	}
+}
+
+// occurrencesBetween returns the occurrences of the programming that overlap the interval from from to to.
+func occurrencesBetween(p config.Programming, from, to time.Time) []config.Occurrence {
+	if p.Recurrence == nil {
+		occurrence := p.OccurrenceAt(p.Date)
+		if occurrence.Start.Before(to) && occurrenceEnd(occurrence, to).After(from) {
+			return []config.Occurrence{occurrence}
+		}
+		return nil
+	}
+
+	var ret []config.Occurrence
+	occurrences := p.Occurrences(from.Add(-p.Recurrence.Duration))
+	for i := 0; i < maxCheckedOccurrences; i++ {
+		occurrence, ok := occurrences.Next()
+		if !ok || !occurrence.Start.Before(to) {
+			break
+		}
+		ret = append(ret, occurrence)
+	}
+	return ret
+}
+
+// occurrenceEnd returns the end of the occurrence, occurrences without an end last until openEnd.
+func occurrenceEnd(occurrence config.Occurrence, openEnd time.Time) time.Time {
+	if occurrence.Until == nil || occurrence.Until.IsZero() {
+		return openEnd
+	}
+	return *occurrence.Until
+}
+
+// peakOverlap returns the maximum amount of the given occurrences that run simultaneously during the occurrence.
+func peakOverlap(occurrence config.Occurrence, others []config.Occurrence, openEnd time.Time) int {
+	type event struct {
+		at    time.Time
+		delta int
+	}
+
+	start, end := occurrence.Start, occurrenceEnd(occurrence, openEnd)
+	var events []event
+	for _, other := range others {
+		otherStart, otherEnd := other.Start, occurrenceEnd(other, openEnd)
+		if !otherStart.Before(end) || !otherEnd.After(start) {
+			continue
+		}
+		if otherStart.Before(start) {
+			otherStart = start
+		}
+		events = append(events, event{at: otherStart, delta: 1}, event{at: otherEnd, delta: -1})
+	}
+
+	// ends are processed before starts at the same time, recordings that follow each other do not overlap
+	sort.Slice(events, func(i, j int) bool {
+		if events[i].at.Equal(events[j].at) {
+			return events[i].delta < events[j].delta
+		}
+		return events[i].at.Before(events[j].at)
+	})
+
+	var current, peak int
+	for _, e := range events {
+		current += e.delta
+		if current > peak {
+			peak = current
+		}
+	}
+	return peak
+}
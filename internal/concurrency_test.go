@@ -0,0 +1,125 @@
+package internal
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+	"vcr/internal/config"
+	"vcr/internal/dbs"
+)
+
+func newTestRecorder(t *testing.T, name string, priority int) *Recorder {
+	t.Helper()
+	r, err := NewRecording(dbs.NewMemoryDb(), newFakeRuntime(), config.Programming{Name: name, Priority: priority}, config.ContainerConfig{}, &sync.WaitGroup{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestSlots_PreemptedRecordingKeepsSlotWhileStopping(t *testing.T) {
+	db := dbs.NewMemoryDb()
+	low := runningProgramming("low")
+	if err := db.Add(low); err != nil {
+		t.Fatal(err)
+	}
+	fake := newFakeRuntime()
+	// the preempted recording only exits after the grace period
+	fake.ignoreStopSignal = true
+	vcr := newTestVcr(t, db, fake, WithConcurrencyLimit(1, config.OverlapPolicyWarn))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	returned := make(chan struct{})
+	go func() {
+		vcr.ControlLoop(ctx, &sync.WaitGroup{})
+		close(returned)
+	}()
+	defer func() {
+		cancel()
+		<-returned
+	}()
+	waitFor(t, 5*time.Second, func() bool { return len(fake.running()) == 1 })
+
+	high := runningProgramming("high")
+	high.Priority = 1
+	if err := db.Add(high); err != nil {
+		t.Fatal(err)
+	}
+	vcr.scheduler.notify(high.Name)
+
+	waitFor(t, 5*time.Second, func() bool {
+		running := fake.running()
+		return len(running) == 1 && running[0] == "high-2"
+	})
+	if peak := fake.peakRunning(); peak != 1 {
+		t.Errorf("peak of running containers = %d, want 1", peak)
+	}
+
+	p, err := db.Find(low.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Status == nil || p.Status.State != config.StateCancelled {
+		t.Errorf("status of preempted recording = %+v, want cancelled", p.Status)
+	}
+}
+
+func TestSlots_AcquireWithoutVictim(t *testing.T) {
+	s := newSlots(1)
+	high := newTestRecorder(t, "high", 1)
+	low := newTestRecorder(t, "low", 0)
+
+	if err := s.acquire(high, nil); err != nil {
+		t.Fatalf("acquire() error = %v", err)
+	}
+	if err := s.acquire(low, nil); err != errConcurrencyLimit {
+		t.Fatalf("acquire() error = %v, want %v", err, errConcurrencyLimit)
+	}
+
+	s.release(high)
+	if err := s.acquire(low, nil); err != nil {
+		t.Fatalf("acquire() after release error = %v", err)
+	}
+}
+
+func TestSlots_AbortWaitingForPreemptedRecording(t *testing.T) {
+	for _, abort := range []string{"cancel", "done"} {
+		t.Run(abort, func(t *testing.T) {
+			s := newSlots(1)
+			low, high := newTestRecorder(t, "low", 0), newTestRecorder(t, "high", 1)
+			if err := s.acquire(low, nil); err != nil {
+				t.Fatal(err)
+			}
+
+			done := make(chan bool, 1)
+			acquired := make(chan error, 1)
+			go func() {
+				acquired <- s.acquire(high, done)
+			}()
+			// the low priority recording is preempted, but keeps its slot until it has stopped
+			<-low.cancel
+
+			if abort == "cancel" {
+				high.Cancel()
+			} else {
+				done <- true
+			}
+			select {
+			case err := <-acquired:
+				if err != errSlotAborted {
+					t.Fatalf("acquire() error = %v, want %v", err, errSlotAborted)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("acquire() has not been aborted")
+			}
+
+			s.release(low)
+			s.mutex.Lock()
+			defer s.mutex.Unlock()
+			if len(s.running) != 0 || len(s.preempted) != 0 {
+				t.Errorf("running = %v, preempted = %v, want no slots taken", s.running, s.preempted)
+			}
+		})
+	}
+}
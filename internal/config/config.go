@@ -1,44 +1,362 @@
 package config
 
 import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
 	"time"
 
 	"github.com/caarlos0/env/v9"
+	"gopkg.in/yaml.v3"
 )
 
 type VcrConfig struct {
-	RuntimeImpl string `yaml:"runtime_impl"`
+	RuntimeImpl string `yaml:"runtime_impl" env:"VCR_RUNTIME_IMPL" validate:"required,oneof=docker podman process kubernetes"`
 
-	Docker struct {
-		DockerHost string `yaml:"docker_host"`
-		ApiVersion string `yaml:"omitempty,api_version"`
-	}
+	// Timezone is the IANA name of the location that times without an explicit offset are interpreted in.
+	Timezone string `yaml:"timezone" env:"VCR_TIMEZONE" validate:"omitempty,timezone"`
+
+	Docker DockerConfig `yaml:"docker"`
+
+	Podman PodmanConfig `yaml:"podman"`
+
+	Process ProcessConfig `yaml:"process"`
+
+	Kubernetes KubernetesConfig `yaml:"kubernetes"`
 
 	ContainerConfig ContainerConfig `yaml:"container_config"`
+
+	Http HttpConfig `yaml:"http"`
+
+	Db DbConfig `yaml:"db"`
+
+	Scheduler SchedulerConfig `yaml:"scheduler"`
+
+	Cleanup CleanupConfig `yaml:"cleanup"`
+
+	Disk DiskConfig `yaml:"disk"`
+
+	Retention RetentionConfig `yaml:"retention"`
+}
+
+// HttpConfig configures the server of the api. The api is served via https if a certificate and key are configured.
+type HttpConfig struct {
+	Address     string `yaml:"address" env:"VCR_HTTP_ADDRESS" validate:"required,hostname_port"`
+	TlsCertFile string `yaml:"tls_cert_file" env:"VCR_HTTP_TLS_CERT_FILE" validate:"required_with=TlsKeyFile,omitempty,file"`
+	TlsKeyFile  string `yaml:"tls_key_file" env:"VCR_HTTP_TLS_KEY_FILE" validate:"required_with=TlsCertFile,omitempty,file"`
+}
+
+// RetentionConfig defines which recorded files are removed from the host path of the mount. All rules are optional
+// and disabled with their zero value.
+type RetentionConfig struct {
+	// MaxAge removes files that have not been modified for the given duration.
+	MaxAge time.Duration `yaml:"max_age" env:"VCR_RETENTION_MAX_AGE" validate:"min=0"`
+	// MaxTotalBytes removes the oldest files until the total size of all recorded files is below the budget.
+	MaxTotalBytes uint64 `yaml:"max_total_bytes" env:"VCR_RETENTION_MAX_TOTAL_BYTES"`
+	// KeepLast is the amount of recordings that are kept per programming.
+	KeepLast int `yaml:"keep_last" env:"VCR_RETENTION_KEEP_LAST" validate:"min=0"`
+	// DryRun only logs the files that would be removed.
+	DryRun   bool          `yaml:"dry_run" env:"VCR_RETENTION_DRY_RUN"`
+	Interval time.Duration `yaml:"interval" env:"VCR_RETENTION_INTERVAL" validate:"min=1m"`
+}
+
+const (
+	DiskPolicyRefuse = "refuse"
+	DiskPolicyWarn   = "warn"
+)
+
+// DiskConfig defines the free space that's required on the host path of the mount. Below the minimum, recordings are
+// either refused and the running recordings with the lowest priority are stopped, or only warned about.
+type DiskConfig struct {
+	// MinFreeBytes is the minimum of free space, 0 disables the check.
+	MinFreeBytes  uint64        `yaml:"min_free_bytes" env:"VCR_DISK_MIN_FREE_BYTES"`
+	Policy        string        `yaml:"policy" env:"VCR_DISK_POLICY" validate:"required,oneof=refuse warn"`
+	CheckInterval time.Duration `yaml:"check_interval" env:"VCR_DISK_CHECK_INTERVAL" validate:"min=1s"`
+}
+
+const (
+	RuntimeImplDocker     = "docker"
+	RuntimeImplPodman     = "podman"
+	RuntimeImplProcess    = "process"
+	RuntimeImplKubernetes = "kubernetes"
+)
+
+// DockerConfig configures the connection to the Docker daemon. Empty values fall back to the DOCKER_* environment
+// variables of the Docker CLI.
+type DockerConfig struct {
+	// DockerHost is the address of the daemon, e.g. "tcp://docker.example.com:2376".
+	DockerHost string `yaml:"docker_host" env:"VCR_DOCKER_HOST"`
+	// ApiVersion pins the version of the API, it's negotiated with the daemon if it's empty.
+	ApiVersion string `yaml:"api_version" env:"VCR_DOCKER_API_VERSION"`
+	// TlsCaCert is the CA certificate the certificate of the daemon is verified with.
+	TlsCaCert string `yaml:"tls_ca_cert" env:"VCR_DOCKER_TLS_CA_CERT" validate:"omitempty,file"`
+	// TlsCert and TlsKey are the client certificate and its key vcr authenticates with.
+	TlsCert string `yaml:"tls_cert" env:"VCR_DOCKER_TLS_CERT" validate:"required_with=TlsKey,omitempty,file"`
+	TlsKey  string `yaml:"tls_key" env:"VCR_DOCKER_TLS_KEY" validate:"required_with=TlsCert,omitempty,file"`
+}
+
+// UsesTls returns whether a CA certificate or a client certificate has been configured.
+func (c DockerConfig) UsesTls() bool {
+	return len(c.TlsCaCert) > 0 || len(c.TlsCert) > 0 || len(c.TlsKey) > 0
+}
+
+// PodmanConfig configures the runtime that talks to the libpod API of Podman.
+type PodmanConfig struct {
+	// Socket is the path of the API socket, the rootless and rootful default sockets are tried if it's empty.
+	Socket string `yaml:"socket" env:"VCR_PODMAN_SOCKET" validate:"omitempty,filepath"`
+}
+
+// ProcessConfig configures the runtime that runs the recorder as local subprocess instead of a container.
+type ProcessConfig struct {
+	// Binary is the name or path of the recorder binary, the image of the container config is ignored.
+	Binary string `yaml:"binary" env:"VCR_PROCESS_BINARY" validate:"required"`
+}
+
+// KubernetesConfig configures the runtime that runs a Job per recording.
+type KubernetesConfig struct {
+	// Kubeconfig is the path of the kubeconfig file, the in-cluster config is used if it's empty.
+	Kubeconfig string `yaml:"kubeconfig" env:"VCR_KUBERNETES_KUBECONFIG" validate:"omitempty,filepath"`
+	Namespace  string `yaml:"namespace" env:"VCR_KUBERNETES_NAMESPACE" validate:"required"`
+	// PersistentVolumeClaim is mounted at the container path of the mount in place of its host path.
+	PersistentVolumeClaim string `yaml:"persistent_volume_claim" env:"VCR_KUBERNETES_PVC"`
+}
+
+const (
+	CleanupPolicyNone     = "none"
+	CleanupPolicyRemove   = "remove"
+	CleanupPolicyKeepLast = "keep_last"
+	CleanupPolicyKeepFor  = "keep_for"
+)
+
+// CleanupConfig defines which stopped containers are removed after a recording ended and on startup.
+type CleanupConfig struct {
+	Policy string `yaml:"policy" env:"VCR_CLEANUP_POLICY" validate:"required,oneof=none remove keep_last keep_for"`
+	// KeepLast is the amount of stopped containers that are kept per programming.
+	KeepLast int `yaml:"keep_last" env:"VCR_CLEANUP_KEEP_LAST" validate:"min=0"`
+	// KeepFor is the duration stopped containers are kept for after they exited.
+	KeepFor time.Duration `yaml:"keep_for" env:"VCR_CLEANUP_KEEP_FOR" validate:"min=0"`
+}
+
+const (
+	CatchUpPolicyStartLate = "start_late"
+	CatchUpPolicySkip      = "skip"
+)
+
+const (
+	OverlapPolicyReject = "reject"
+	OverlapPolicyWarn   = "warn"
+)
+
+type SchedulerConfig struct {
+	// LeadTime defines how long before its start a recording is created.
+	LeadTime time.Duration `yaml:"lead_time" env:"VCR_SCHEDULER_LEAD_TIME" validate:"min=1s"`
+	// PaddingBefore and PaddingAfter are the default padding of programmings that don't define their own padding.
+	PaddingBefore time.Duration `yaml:"padding_before" env:"VCR_SCHEDULER_PADDING_BEFORE" validate:"min=0"`
+	PaddingAfter  time.Duration `yaml:"padding_after" env:"VCR_SCHEDULER_PADDING_AFTER" validate:"min=0"`
+	// CatchUpPolicy defines whether a programming whose start has been missed, e.g. because vcr was not running, is
+	// recorded late if it has not ended yet. Missed programmings are marked as missed in their status. With start_late,
+	// recordings that can't be started, e.g. because the container runtime is down, are retried until their end.
+	CatchUpPolicy string `yaml:"catch_up_policy" env:"VCR_SCHEDULER_CATCH_UP_POLICY" validate:"required,oneof=start_late skip"`
+	// MaxDuration stops recordings of programmings without an end after the given duration, 0 disables the limit.
+	MaxDuration time.Duration `yaml:"max_duration" env:"VCR_SCHEDULER_MAX_DURATION" validate:"min=0"`
+	// MaxConcurrentRecordings limits the amount of simultaneous recordings, 0 disables the limit.
+	MaxConcurrentRecordings int `yaml:"max_concurrent_recordings" env:"VCR_SCHEDULER_MAX_CONCURRENT_RECORDINGS" validate:"min=0"`
+	// OverlapPolicy defines whether programmings that would exceed MaxConcurrentRecordings are rejected or only
+	// warned about.
+	OverlapPolicy string `yaml:"overlap_policy" env:"VCR_SCHEDULER_OVERLAP_POLICY" validate:"required,oneof=reject warn"`
+}
+
+// Location returns the location configured via Timezone, defaulting to local time.
+func (c *VcrConfig) Location() (*time.Location, error) {
+	if len(c.Timezone) == 0 {
+		return time.Local, nil
+	}
+	return time.LoadLocation(c.Timezone)
+}
+
+const (
+	DbImplMemory = "memory"
+	DbImplSqlite = "sqlite"
+	DbImplYaml   = "yaml"
+)
+
+type DbConfig struct {
+	Impl        string `yaml:"impl" env:"VCR_DB_IMPL" validate:"required,oneof=memory sqlite yaml"`
+	HistoryImpl string `yaml:"history_impl" env:"VCR_DB_HISTORY_IMPL" validate:"required,oneof=memory sqlite"`
+	SqlitePath  string `yaml:"sqlite_path" env:"VCR_DB_SQLITE_PATH" validate:"required_if=Impl sqlite,required_if=HistoryImpl sqlite,omitempty,filepath"`
+	// YamlPath is the file programmings are read from, the status of their recordings is kept in a sidecar file next
+	// to it, e.g. "programmings.state.yaml".
+	YamlPath string `yaml:"yaml_path" env:"VCR_DB_YAML_PATH" validate:"required_if=Impl yaml,omitempty,filepath"`
 }
 
 type Programming struct {
 	Url   string     `yaml:"url" validate:"required,url"`
 	Name  string     `yaml:"name" validate:"required"`
 	Date  time.Time  `yaml:"start" validate:"required"`
-	Until *time.Time `yaml:"end,omitempty" validate:"omitempty,datetime"`
+	Until *time.Time `yaml:"end,omitempty"`
+
+	Recurrence *Recurrence  `yaml:"recurrence,omitempty"`
+	Retry      *RetryPolicy `yaml:"retry,omitempty"`
+	// Priority decides which recordings are preempted if the limit of concurrent recordings is reached, recordings
+	// with a higher priority preempt recordings with a lower priority.
+	Priority int `yaml:"priority,omitempty"`
+	// Padding extends the recording beyond the start and end of the programming.
+	Padding *Padding `yaml:"padding,omitempty"`
+
+	Status *RecordingStatus `yaml:"status,omitempty"`
+}
+
+// Validate checks the programming including its recurrence and retry policy, e.g. after it has been read from a file
+// that has been edited by hand.
+func (p *Programming) Validate() error {
+	if err := Validate(p); err != nil {
+		return err
+	}
+	if p.Recurrence != nil {
+		if err := p.Recurrence.Validate(); err != nil {
+			return fmt.Errorf("invalid recurrence: %w", err)
+		}
+	}
+	if p.Retry != nil {
+		if err := p.Retry.Validate(); err != nil {
+			return fmt.Errorf("invalid retry policy: %w", err)
+		}
+	}
+	return nil
+}
+
+// Clone returns a deep copy of the programming, so changes to the copy never affect the original.
+func (p Programming) Clone() Programming {
+	ret := p
+	if p.Until != nil {
+		until := *p.Until
+		ret.Until = &until
+	}
+	if p.Recurrence != nil {
+		ret.Recurrence = p.Recurrence.clone()
+	}
+	if p.Retry != nil {
+		retry := *p.Retry
+		ret.Retry = &retry
+	}
+	if p.Padding != nil {
+		padding := *p.Padding
+		ret.Padding = &padding
+	}
+	if p.Status != nil {
+		ret.Status = p.Status.clone()
+	}
+	return ret
+}
+
+// Padding extends a recording to compensate for broadcasts that don't start or end on time.
+type Padding struct {
+	Before time.Duration `yaml:"before,omitempty"`
+	After  time.Duration `yaml:"after,omitempty"`
+}
+
+// Apply returns the occurrence extended by the padding.
+func (p Padding) Apply(occurrence Occurrence) Occurrence {
+	ret := Occurrence{Start: occurrence.Start.Add(-p.Before)}
+	if occurrence.Until != nil && !occurrence.Until.IsZero() {
+		until := occurrence.Until.Add(p.After)
+		ret.Until = &until
+	}
+	return ret
+}
+
+// PaddingOrDefault returns the padding of the programming, or the given default if it has no padding.
+func (p *Programming) PaddingOrDefault(def Padding) Padding {
+	if p.Padding != nil {
+		return *p.Padding
+	}
+	return def
+}
+
+// PaddedOccurrence returns start and end of the recording of the programming including its padding.
+func (p *Programming) PaddedOccurrence() Occurrence {
+	return p.PaddingOrDefault(Padding{}).Apply(Occurrence{Start: p.Date, Until: p.Until})
 }
 
 func (p *Programming) IsUpcoming() bool {
-	return p.Date.After(time.Now())
+	_, ok := p.NextOccurrence(time.Now())
+	return ok
 }
 
-func GetContainerConfig() (ContainerConfig, error) {
+// GetConfig returns the default config, overridden by the yaml file at path and then by environment variables. The
+// file is optional, no file is read if path is empty.
+func GetConfig(path string) (VcrConfig, error) {
 	conf := getDefaultConfig()
+	if len(path) > 0 {
+		if err := readConfigFile(path, &conf); err != nil {
+			return conf, err
+		}
+	}
+
 	err := env.Parse(&conf)
 	return conf, err
 }
 
-func getDefaultConfig() ContainerConfig {
-	return ContainerConfig{
-		Image: "ghcr.io/soerenschneider/yt-dlp:main",
-		Mount: &Mount{
-			ContainerPath: ".",
+// readConfigFile decodes the yaml file at path into conf, keys that are missing in the file keep their value. Unknown
+// keys are rejected to catch typos.
+func readConfigFile(path string, conf *VcrConfig) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	decoder := yaml.NewDecoder(file)
+	decoder.KnownFields(true)
+	if err := decoder.Decode(conf); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("could not parse %s: %w", path, err)
+	}
+	return nil
+}
+
+func getDefaultConfig() VcrConfig {
+	return VcrConfig{
+		RuntimeImpl: RuntimeImplDocker,
+		Process: ProcessConfig{
+			Binary: "yt-dlp",
+		},
+		Kubernetes: KubernetesConfig{
+			Namespace: "default",
+		},
+		Http: HttpConfig{
+			Address: ":9999",
+		},
+		ContainerConfig: ContainerConfig{
+			Image: "ghcr.io/soerenschneider/yt-dlp:main",
+			Mount: &Mount{
+				ContainerPath: ".",
+			},
+			StopSignal:      "SIGINT",
+			StopGracePeriod: 30 * time.Second,
+		},
+		Db: DbConfig{
+			Impl:        DbImplMemory,
+			HistoryImpl: DbImplMemory,
+		},
+		Scheduler: SchedulerConfig{
+			LeadTime:      30 * time.Second,
+			CatchUpPolicy: CatchUpPolicyStartLate,
+			MaxDuration:   12 * time.Hour,
+			OverlapPolicy: OverlapPolicyReject,
+		},
+		Disk: DiskConfig{
+			MinFreeBytes:  1 << 30,
+			Policy:        DiskPolicyRefuse,
+			CheckInterval: 30 * time.Second,
+		},
+		Retention: RetentionConfig{
+			Interval: time.Hour,
+		},
+		Cleanup: CleanupConfig{
+			Policy:   CleanupPolicyKeepLast,
+			KeepLast: 3,
+			KeepFor:  24 * time.Hour,
 		},
 	}
 }
@@ -53,4 +371,12 @@ type ContainerConfig struct {
 	Image string   `yaml:"image" env:"VCR_IMAGE" validate:"required"`
 	Mount *Mount   `yaml:"mount"`
 	Args  []string `yaml:"args"`
+
+	// StopSignal is sent to the container to stop a recording, SIGKILL is sent if the container did not exit after
+	// StopGracePeriod.
+	StopSignal      string        `yaml:"stop_signal" env:"VCR_STOP_SIGNAL" validate:"required,oneof=SIGINT SIGTERM SIGQUIT SIGHUP SIGKILL"`
+	StopGracePeriod time.Duration `yaml:"stop_grace_period" env:"VCR_STOP_GRACE_PERIOD" validate:"min=0"`
+
+	// KeepRunningOnShutdown leaves running containers untouched when vcr shuts down, they're adopted again on startup.
+	KeepRunningOnShutdown bool `yaml:"keep_running_on_shutdown" env:"VCR_KEEP_RUNNING_ON_SHUTDOWN"`
 }
@@ -0,0 +1,251 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"github.com/teambition/rrule-go"
+)
+
+const ExceptionDateFormat = "2006-01-02"
+
+// maxSkippedOccurrences limits the amount of occurrences that are skipped due to exceptions while searching for the
+// next occurrence.
+const maxSkippedOccurrences = 1000
+
+// Recurrence describes a programming that repeats. Either Cron or RRule must be set. The Date of the programming
+// marks the beginning of the series, the Until of the programming its end.
+type Recurrence struct {
+	Cron     string        `yaml:"cron,omitempty"`
+	RRule    string        `yaml:"rrule,omitempty"`
+	Duration time.Duration `yaml:"duration"`
+	// Timezone is the IANA name of the location the cron expression or rrule is evaluated in, defaults to local time.
+	Timezone string `yaml:"timezone,omitempty"`
+	// Exceptions contains dates in the format of ExceptionDateFormat that are skipped.
+	Exceptions []string `yaml:"exceptions,omitempty"`
+}
+
+// Occurrence is a single recording of a programming.
+type Occurrence struct {
+	Start time.Time
+	Until *time.Time
+}
+
+// nextFunc returns the first start of the series after the given time or the zero time if there is none. It must be
+// called with times that don't decrease, since it continues the search where the previous call stopped.
+type nextFunc func(after time.Time) time.Time
+
+func (r *Recurrence) clone() *Recurrence {
+	ret := *r
+	if r.Exceptions != nil {
+		ret.Exceptions = append([]string(nil), r.Exceptions...)
+	}
+	return &ret
+}
+
+func (r *Recurrence) Validate() error {
+	if len(r.Cron) > 0 && len(r.RRule) > 0 {
+		return errors.New("only one of cron and rrule may be set")
+	}
+
+	if r.Duration <= 0 {
+		return errors.New("duration of a recurrence must be positive")
+	}
+
+	for _, exception := range r.Exceptions {
+		if _, err := time.Parse(ExceptionDateFormat, exception); err != nil {
+			return fmt.Errorf("invalid exception %q: %w", exception, err)
+		}
+	}
+
+	_, err := r.next(time.Now())
+	return err
+}
+
+func (r *Recurrence) location() (*time.Location, error) {
+	if len(r.Timezone) == 0 {
+		return time.Local, nil
+	}
+	return time.LoadLocation(r.Timezone)
+}
+
+func (r *Recurrence) next(seriesStart time.Time) (nextFunc, error) {
+	loc, err := r.location()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(r.Cron) > 0 {
+		schedule, err := cron.ParseStandard(r.Cron)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cron expression: %w", err)
+		}
+		return func(after time.Time) time.Time {
+			return schedule.Next(after.In(loc))
+		}, nil
+	}
+
+	if len(r.RRule) > 0 {
+		opts, err := rrule.StrToROptionInLocation(r.RRule, loc)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rrule: %w", err)
+		}
+		if opts.Dtstart.IsZero() {
+			opts.Dtstart = seriesStart.In(loc)
+		}
+		rule, err := rrule.NewRRule(*opts)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rrule: %w", err)
+		}
+		// rule.After would iterate the series from its beginning on every call
+		iterator := rule.Iterator()
+		var last time.Time
+		ok := true
+		return func(after time.Time) time.Time {
+			for ok && !last.After(after) {
+				last, ok = iterator()
+			}
+			if !ok {
+				return time.Time{}
+			}
+			return last
+		}, nil
+	}
+
+	return nil, errors.New("either cron or rrule must be set")
+}
+
+func (r *Recurrence) isException(t time.Time) bool {
+	loc, err := r.location()
+	if err != nil {
+		return false
+	}
+
+	date := t.In(loc).Format(ExceptionDateFormat)
+	for _, exception := range r.Exceptions {
+		if exception == date {
+			return true
+		}
+	}
+	return false
+}
+
+// OccurrenceIterator returns the occurrences of a programming in chronological order. The recurrence is parsed once
+// and the series is only iterated once, no matter how many occurrences are requested.
+type OccurrenceIterator struct {
+	programming *Programming
+	next        nextFunc
+	after       time.Time
+	done        bool
+}
+
+// Occurrences returns an iterator over the occurrences of the programming that start after the given time.
+func (p *Programming) Occurrences(after time.Time) *OccurrenceIterator {
+	it := &OccurrenceIterator{programming: p, after: after}
+	if p.Recurrence == nil {
+		return it
+	}
+
+	next, err := p.Recurrence.next(p.Date)
+	if err != nil {
+		it.done = true
+		return it
+	}
+	it.next = next
+
+	// occurrences before the beginning of the series are not considered
+	if p.Date.After(after) {
+		it.after = p.Date.Add(-time.Nanosecond)
+	}
+	return it
+}
+
+// Next returns the next occurrence, false if there are no more occurrences.
+func (it *OccurrenceIterator) Next() (Occurrence, bool) {
+	p := it.programming
+	if it.done {
+		return Occurrence{}, false
+	}
+
+	if p.Recurrence == nil {
+		it.done = true
+		if !p.Date.After(it.after) {
+			return Occurrence{}, false
+		}
+		return p.OccurrenceAt(p.Date), true
+	}
+
+	for i := 0; i < maxSkippedOccurrences; i++ {
+		start := it.next(it.after)
+		if start.IsZero() || (p.Until != nil && !p.Until.IsZero() && start.After(*p.Until)) {
+			it.done = true
+			return Occurrence{}, false
+		}
+		it.after = start
+		if !p.Recurrence.isException(start) {
+			return p.OccurrenceAt(start), true
+		}
+	}
+
+	it.done = true
+	return Occurrence{}, false
+}
+
+// NextOccurrence returns the next occurrence of the programming that starts after the given time. For programmings
+// without a recurrence, this is the programming itself if it's still upcoming.
+func (p *Programming) NextOccurrence(after time.Time) (Occurrence, bool) {
+	return p.Occurrences(after).Next()
+}
+
+// OccurrenceContaining returns the occurrence of the programming that has started at or before the given time and has
+// not ended yet at that time.
+func (p *Programming) OccurrenceContaining(t time.Time) (Occurrence, bool) {
+	if p.Recurrence == nil {
+		if p.Date.After(t) {
+			return Occurrence{}, false
+		}
+		return p.OccurrenceAt(p.Date), true
+	}
+
+	occurrence, ok := p.NextOccurrence(t.Add(-p.Recurrence.Duration))
+	if !ok || occurrence.Start.After(t) {
+		return Occurrence{}, false
+	}
+	return occurrence, true
+}
+
+// LastOccurrence returns the latest occurrence of the programming that starts after the first and not after the second
+// given time.
+func (p *Programming) LastOccurrence(after, notAfter time.Time) (Occurrence, bool) {
+	var last Occurrence
+	var found bool
+	occurrences := p.Occurrences(after)
+	for {
+		occurrence, ok := occurrences.Next()
+		if !ok || occurrence.Start.After(notAfter) {
+			return last, found
+		}
+		last, found = occurrence, true
+	}
+}
+
+// OccurrenceAt returns the occurrence of the programming that starts at the given time.
+func (p *Programming) OccurrenceAt(start time.Time) Occurrence {
+	if p.Recurrence == nil {
+		return Occurrence{Start: start, Until: p.Until}
+	}
+
+	until := start.Add(p.Recurrence.Duration)
+	return Occurrence{Start: start, Until: &until}
+}
+
+// ForOccurrence returns a copy of the programming that describes a single occurrence.
+func (p *Programming) ForOccurrence(occurrence Occurrence) Programming {
+	ret := *p
+	ret.Date = occurrence.Start
+	ret.Until = occurrence.Until
+	ret.Recurrence = nil
+	return ret
+}
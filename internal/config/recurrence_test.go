@@ -0,0 +1,171 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestProgramming_NextOccurrence(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	seriesStart := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	seriesEnd := time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		recurrence *Recurrence
+		until      *time.Time
+		after      time.Time
+		want       time.Time
+		wantOk     bool
+	}{
+		{
+			name:   "single",
+			after:  seriesStart.Add(-time.Minute),
+			want:   seriesStart,
+			wantOk: true,
+		},
+		{
+			name:  "single in the past",
+			after: seriesStart,
+		},
+		{
+			name:       "cron",
+			recurrence: &Recurrence{Cron: "0 20 * * *", Duration: time.Hour, Timezone: "UTC"},
+			after:      time.Date(2024, 3, 5, 20, 0, 0, 0, time.UTC),
+			want:       time.Date(2024, 3, 6, 20, 0, 0, 0, time.UTC),
+			wantOk:     true,
+		},
+		{
+			name:       "cron in timezone across dst",
+			recurrence: &Recurrence{Cron: "0 20 * * *", Duration: time.Hour, Timezone: "Europe/Berlin"},
+			after:      time.Date(2024, 3, 30, 20, 0, 0, 0, berlin),
+			want:       time.Date(2024, 3, 31, 18, 0, 0, 0, time.UTC),
+			wantOk:     true,
+		},
+		{
+			name:       "rrule in timezone",
+			recurrence: &Recurrence{RRule: "FREQ=WEEKLY;BYDAY=FR;BYHOUR=20;BYMINUTE=15;BYSECOND=0", Duration: time.Hour, Timezone: "Europe/Berlin"},
+			after:      time.Date(2024, 3, 30, 0, 0, 0, 0, time.UTC),
+			want:       time.Date(2024, 4, 5, 18, 15, 0, 0, time.UTC),
+			wantOk:     true,
+		},
+		{
+			name:       "before the series",
+			recurrence: &Recurrence{Cron: "0 20 * * *", Duration: time.Hour, Timezone: "UTC"},
+			after:      seriesStart.Add(-72 * time.Hour),
+			want:       time.Date(2024, 3, 1, 20, 0, 0, 0, time.UTC),
+			wantOk:     true,
+		},
+		{
+			name:       "exceptions",
+			recurrence: &Recurrence{Cron: "0 20 * * *", Duration: time.Hour, Timezone: "UTC", Exceptions: []string{"2024-03-06", "2024-03-07"}},
+			after:      time.Date(2024, 3, 5, 20, 0, 0, 0, time.UTC),
+			want:       time.Date(2024, 3, 8, 20, 0, 0, 0, time.UTC),
+			wantOk:     true,
+		},
+		{
+			name:       "exception in timezone",
+			recurrence: &Recurrence{Cron: "30 0 * * *", Duration: time.Hour, Timezone: "Europe/Berlin", Exceptions: []string{"2024-03-06"}},
+			// 23:30 UTC is already the 6th in Berlin
+			after:  time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC),
+			want:   time.Date(2024, 3, 6, 23, 30, 0, 0, time.UTC),
+			wantOk: true,
+		},
+		{
+			name:       "end of the series",
+			recurrence: &Recurrence{Cron: "0 20 * * *", Duration: time.Hour, Timezone: "UTC"},
+			until:      &seriesEnd,
+			after:      time.Date(2024, 3, 9, 20, 0, 0, 0, time.UTC),
+		},
+		{
+			name:       "count of the rrule",
+			recurrence: &Recurrence{RRule: "FREQ=DAILY;COUNT=3", Duration: time.Hour, Timezone: "UTC"},
+			after:      time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC),
+			want:       time.Date(2024, 3, 3, 0, 0, 0, 0, time.UTC),
+			wantOk:     true,
+		},
+		{
+			name:       "count of the rrule exhausted",
+			recurrence: &Recurrence{RRule: "FREQ=DAILY;COUNT=3", Duration: time.Hour, Timezone: "UTC"},
+			after:      time.Date(2024, 3, 3, 0, 0, 0, 0, time.UTC),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := Programming{Name: "news", Date: seriesStart, Until: tt.until, Recurrence: tt.recurrence}
+
+			got, ok := p.NextOccurrence(tt.after)
+			if ok != tt.wantOk || !got.Start.Equal(tt.want) {
+				t.Fatalf("NextOccurrence() = %v, %v, want %v, %v", got.Start, ok, tt.want, tt.wantOk)
+			}
+			if ok && tt.recurrence != nil && !got.Until.Equal(tt.want.Add(tt.recurrence.Duration)) {
+				t.Errorf("NextOccurrence() until = %v, want start + duration", got.Until)
+			}
+		})
+	}
+}
+
+func TestProgramming_Occurrences(t *testing.T) {
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name       string
+		recurrence Recurrence
+	}{
+		{name: "cron", recurrence: Recurrence{Cron: "0 */6 * * *", Duration: time.Hour, Timezone: "UTC", Exceptions: []string{"2024-03-02"}}},
+		{name: "rrule", recurrence: Recurrence{RRule: "FREQ=HOURLY;INTERVAL=6", Duration: time.Hour, Timezone: "UTC", Exceptions: []string{"2024-03-02"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := Programming{Name: "news", Date: start, Recurrence: &tt.recurrence}
+
+			var got []time.Time
+			occurrences := p.Occurrences(start.Add(time.Hour))
+			for i := 0; i < 5; i++ {
+				occurrence, ok := occurrences.Next()
+				if !ok {
+					t.Fatalf("Next() = false after %v", got)
+				}
+				got = append(got, occurrence.Start)
+			}
+
+			want := []time.Time{start.Add(6 * time.Hour), start.Add(12 * time.Hour), start.Add(18 * time.Hour), start.Add(48 * time.Hour), start.Add(54 * time.Hour)}
+			for i := range want {
+				if !got[i].Equal(want[i]) {
+					t.Fatalf("occurrences = %v, want %v", got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestProgramming_LastOccurrence(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Minute)
+	tests := []struct {
+		name       string
+		recurrence Recurrence
+		want       time.Time
+	}{
+		{name: "cron", recurrence: Recurrence{Cron: "* * * * *", Duration: time.Minute, Timezone: "UTC"}, want: now},
+		{name: "hourly rrule", recurrence: Recurrence{RRule: "FREQ=HOURLY", Duration: time.Minute, Timezone: "UTC"}, want: now.Truncate(time.Hour)},
+		{name: "minutely rrule", recurrence: Recurrence{RRule: "FREQ=MINUTELY", Duration: time.Minute, Timezone: "UTC"}, want: now},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// a series that has begun long ago must not be iterated from its beginning for every occurrence
+			seriesStart := now.Truncate(time.Hour).AddDate(-2, 0, 0)
+			p := Programming{Name: "news", Date: seriesStart, Recurrence: &tt.recurrence}
+
+			began := time.Now()
+			got, ok := p.LastOccurrence(now.Add(-24*time.Hour), now)
+			if !ok || !got.Start.Equal(tt.want) {
+				t.Errorf("LastOccurrence() = %v, %v, want %v", got.Start, ok, tt.want)
+			}
+			if elapsed := time.Since(began); elapsed > 5*time.Second {
+				t.Errorf("LastOccurrence() took %v", elapsed)
+			}
+		})
+	}
+}
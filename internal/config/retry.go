@@ -0,0 +1,46 @@
+package config
+
+import (
+	"errors"
+	"time"
+)
+
+const (
+	DefaultRetryInitialBackoff = 5 * time.Second
+	DefaultRetryMaxBackoff     = 5 * time.Minute
+)
+
+// RetryPolicy defines how often a recording is restarted if its container exits before the end of the programming.
+// Restarts are only attempted before the end of the programming.
+type RetryPolicy struct {
+	// MaxAttempts is the total amount of attempts including the first one.
+	MaxAttempts    int           `yaml:"max_attempts"`
+	InitialBackoff time.Duration `yaml:"initial_backoff"`
+	MaxBackoff     time.Duration `yaml:"max_backoff"`
+}
+
+func (p *RetryPolicy) Validate() error {
+	if p.MaxAttempts < 1 {
+		return errors.New("max attempts must be at least 1")
+	}
+	if p.InitialBackoff <= 0 || p.MaxBackoff <= 0 {
+		return errors.New("backoff must be positive")
+	}
+	if p.InitialBackoff > p.MaxBackoff {
+		return errors.New("initial backoff must not exceed max backoff")
+	}
+	return nil
+}
+
+// Backoff returns the time to wait after the given amount of failed attempts. The backoff doubles with each failed
+// attempt and is capped by MaxBackoff.
+func (p *RetryPolicy) Backoff(failedAttempts int) time.Duration {
+	backoff := p.InitialBackoff
+	for i := 1; i < failedAttempts; i++ {
+		backoff *= 2
+		if backoff >= p.MaxBackoff {
+			return p.MaxBackoff
+		}
+	}
+	return min(backoff, p.MaxBackoff)
+}
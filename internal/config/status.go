@@ -0,0 +1,154 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var ErrInvalidTransition = errors.New("invalid state transition")
+
+type RecordingState string
+
+const (
+	StateScheduled RecordingState = "scheduled"
+	StateRecording RecordingState = "recording"
+	StateRetrying  RecordingState = "retrying"
+	StateStopping  RecordingState = "stopping"
+	StateCompleted RecordingState = "completed"
+	StateFailed    RecordingState = "failed"
+	StateCancelled RecordingState = "cancelled"
+	// StateMissed marks a recording that has not been started during its window, e.g. because vcr was not running.
+	StateMissed RecordingState = "missed"
+	// StateInterrupted marks a recording that has been stopped because vcr shut down. It's not terminal, the
+	// occurrence is recorded late after a restart if it has not ended yet.
+	StateInterrupted RecordingState = "interrupted"
+)
+
+var allowedTransitions = map[RecordingState][]RecordingState{
+	"":             {StateScheduled},
+	StateScheduled: {StateScheduled, StateRecording, StateRetrying, StateFailed, StateCancelled, StateMissed},
+	// recordings that have been interrupted, e.g. by a restart of vcr, are scheduled again or missed
+	StateRecording:   {StateStopping, StateRetrying, StateCompleted, StateFailed, StateScheduled, StateMissed},
+	StateRetrying:    {StateRecording, StateRetrying, StateFailed, StateCancelled, StateScheduled, StateMissed, StateInterrupted},
+	StateStopping:    {StateCompleted, StateFailed, StateCancelled, StateScheduled, StateMissed, StateInterrupted},
+	StateCompleted:   {StateScheduled, StateMissed},
+	StateFailed:      {StateScheduled, StateMissed},
+	StateCancelled:   {StateScheduled, StateMissed},
+	StateMissed:      {StateScheduled, StateMissed},
+	StateInterrupted: {StateScheduled, StateMissed},
+}
+
+type StateTransition struct {
+	State   RecordingState `yaml:"state"`
+	Time    time.Time      `yaml:"time"`
+	Message string         `yaml:"message,omitempty"`
+}
+
+// RecordingStatus describes the most recent recording of a programming.
+type RecordingStatus struct {
+	State       RecordingState    `yaml:"state"`
+	Transitions []StateTransition `yaml:"transitions,omitempty"`
+	// Attempts contains all finished attempts of the recording.
+	Attempts []RecordingRun `yaml:"attempts,omitempty"`
+}
+
+func (s *RecordingStatus) clone() *RecordingStatus {
+	ret := &RecordingStatus{State: s.State}
+	if s.Transitions != nil {
+		ret.Transitions = append([]StateTransition(nil), s.Transitions...)
+	}
+	if s.Attempts != nil {
+		ret.Attempts = make([]RecordingRun, 0, len(s.Attempts))
+		for _, run := range s.Attempts {
+			ret.Attempts = append(ret.Attempts, run.clone())
+		}
+	}
+	return ret
+}
+
+// IsTerminal returns whether the recording has ended.
+func (s RecordingState) IsTerminal() bool {
+	return s == StateCompleted || s == StateFailed || s == StateCancelled || s == StateMissed
+}
+
+// HandledSince returns the time after which occurrences of the programming have not been handled yet. Occurrences
+// starting before have either been recorded or are currently being recorded.
+func (s *RecordingStatus) HandledSince() time.Time {
+	if len(s.Transitions) == 0 {
+		return time.Time{}
+	}
+
+	if s.State.IsTerminal() {
+		return s.Transitions[len(s.Transitions)-1].Time
+	}
+
+	// an interrupted occurrence is caught up like one that has not been recorded at all. The time it has been scheduled
+	// can't be used, it's after the start of the occurrence if the occurrence has been started late
+	if s.State == StateInterrupted {
+		return time.Time{}
+	}
+
+	// the recording has been interrupted, its occurrence has been scheduled with the latest transition to scheduled
+	for i := len(s.Transitions) - 1; i >= 0; i-- {
+		if s.Transitions[i].State == StateScheduled {
+			return s.Transitions[i].Time
+		}
+	}
+	return time.Time{}
+}
+
+// Transition moves the status to the given state. Scheduling or missing a recording after a previous recording has
+// ended starts a new history of transitions.
+func (s *RecordingStatus) Transition(to RecordingState, message string, now time.Time) error {
+	if !s.canTransition(to) {
+		return fmt.Errorf("%w: %q -> %q", ErrInvalidTransition, s.State, to)
+	}
+
+	if (to == StateScheduled || to == StateMissed) && s.State.IsTerminal() {
+		s.Transitions = nil
+		s.Attempts = nil
+	}
+
+	s.State = to
+	s.Transitions = append(s.Transitions, StateTransition{
+		State:   to,
+		Time:    now,
+		Message: message,
+	})
+	return nil
+}
+
+func (s *RecordingStatus) canTransition(to RecordingState) bool {
+	for _, allowed := range allowedTransitions[s.State] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+// RecordingRun describes a single attempt of a recording.
+type RecordingRun struct {
+	Name        string     `yaml:"name"`
+	Attempt     int        `yaml:"attempt"`
+	ContainerId string     `yaml:"container_id"`
+	Start       time.Time  `yaml:"start"`
+	Stop        *time.Time `yaml:"stop,omitempty"`
+	ExitCode    *int64     `yaml:"exit_code,omitempty"`
+	OutputFile  string     `yaml:"output_file,omitempty"`
+	OutputSize  int64      `yaml:"output_size,omitempty"`
+	Error       string     `yaml:"error,omitempty"`
+}
+
+func (r RecordingRun) clone() RecordingRun {
+	if r.Stop != nil {
+		stop := *r.Stop
+		r.Stop = &stop
+	}
+	if r.ExitCode != nil {
+		exitCode := *r.ExitCode
+		r.ExitCode = &exitCode
+	}
+	return r
+}
@@ -0,0 +1,40 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRecordingStatus_HandledSince(t *testing.T) {
+	scheduled := time.Date(2024, 3, 1, 20, 0, 0, 0, time.UTC)
+	ended := scheduled.Add(time.Hour)
+
+	tests := []struct {
+		name   string
+		states []RecordingState
+		want   time.Time
+	}{
+		{name: "completed", states: []RecordingState{StateScheduled, StateRecording, StateStopping, StateCompleted}, want: ended},
+		{name: "cancelled", states: []RecordingState{StateScheduled, StateRecording, StateStopping, StateCancelled}, want: ended},
+		{name: "crashed while recording", states: []RecordingState{StateScheduled, StateRecording}, want: scheduled},
+		{name: "interrupted by shutdown", states: []RecordingState{StateScheduled, StateRecording, StateStopping, StateInterrupted}},
+		{name: "interrupted while retrying", states: []RecordingState{StateScheduled, StateRetrying, StateInterrupted}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status := &RecordingStatus{}
+			for i, state := range tt.states {
+				now := scheduled
+				if i == len(tt.states)-1 && i > 1 {
+					now = ended
+				}
+				if err := status.Transition(state, "", now); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if got := status.HandledSince(); !got.Equal(tt.want) {
+				t.Errorf("HandledSince() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
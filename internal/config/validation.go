@@ -2,8 +2,32 @@ package config
 
 import "github.com/go-playground/validator/v10"
 
-var validate *validator.Validate = validator.New()
+var validate *validator.Validate = newValidator()
+
+func newValidator() *validator.Validate {
+	v := validator.New()
+	v.RegisterStructValidation(validateKeepRunning, VcrConfig{})
+	return v
+}
 
 func Validate(s any) error {
 	return validate.Struct(s)
 }
+
+// validateKeepRunning rejects keeping containers running on shutdown if they can't be adopted on startup: the memory
+// db forgets the programmings they belong to, and processes of the process runtime don't outlive vcr.
+func validateKeepRunning(sl validator.StructLevel) {
+	c := sl.Current().Interface().(VcrConfig)
+	if !c.ContainerConfig.KeepRunningOnShutdown {
+		return
+	}
+
+	if c.Db.Impl == DbImplMemory {
+		sl.ReportError(c.ContainerConfig.KeepRunningOnShutdown, "ContainerConfig.KeepRunningOnShutdown",
+			"KeepRunningOnShutdown", "persistent_db", "")
+	}
+	if c.RuntimeImpl == RuntimeImplProcess {
+		sl.ReportError(c.ContainerConfig.KeepRunningOnShutdown, "ContainerConfig.KeepRunningOnShutdown",
+			"KeepRunningOnShutdown", "container_runtime", "")
+	}
+}
@@ -0,0 +1,30 @@
+package config
+
+import "testing"
+
+func TestValidate_KeepRunningOnShutdown(t *testing.T) {
+	tests := []struct {
+		name    string
+		runtime string
+		db      string
+		wantErr bool
+	}{
+		{name: "docker and sqlite", runtime: RuntimeImplDocker, db: DbImplSqlite},
+		{name: "memory db", runtime: RuntimeImplDocker, db: DbImplMemory, wantErr: true},
+		{name: "process runtime", runtime: RuntimeImplProcess, db: DbImplSqlite, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conf := getDefaultConfig()
+			conf.RuntimeImpl = tt.runtime
+			conf.Process.Binary = "recorder"
+			conf.Db.Impl = tt.db
+			conf.Db.SqlitePath = "vcr.db"
+			conf.ContainerConfig.KeepRunningOnShutdown = true
+
+			if err := Validate(conf); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -1,17 +1,35 @@
 package dbs
 
 import (
+	"context"
 	"errors"
+	"sync"
 	"vcr/internal/config"
 )
 
 var ErrNotFound = errors.New("not found")
 
+var errNameChanged = errors.New("name of a programming can not be changed")
+
+// Db stores programmings. Implementations must be safe for concurrent use.
 type Db interface {
+	// Add inserts the programming or replaces an existing programming with the same name.
 	Add(programming config.Programming) error
+	// Delete removes the programming, it is not an error if it does not exist.
 	Delete(name string) error
+	// Find returns ErrNotFound if no programming with the given name exists.
 	Find(name string) (*config.Programming, error)
 	List() ([]config.Programming, error)
+	// Update atomically applies fn to the programming with the given name. No other write happens between reading
+	// and writing back the programming. If fn returns an error, the programming remains unchanged. Returns
+	// ErrNotFound if no programming with the given name exists.
+	Update(name string, fn func(*config.Programming) error) error
+}
+
+// Watcher is implemented by dbs that need to observe changes made outside of vcr. The onChange callback is invoked
+// after such changes have been loaded.
+type Watcher interface {
+	Watch(ctx context.Context, wg *sync.WaitGroup, onChange func()) error
 }
 
 func NewMemoryDb() *MemoryDb {
@@ -19,31 +37,67 @@ func NewMemoryDb() *MemoryDb {
 }
 
 type MemoryDb struct {
-	db map[string]config.Programming
+	mutex sync.RWMutex
+	db    map[string]config.Programming
 }
 
 func (d *MemoryDb) Add(programming config.Programming) error {
-	d.db[programming.Name] = programming
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	d.db[programming.Name] = programming.Clone()
 	return nil
 }
 
 func (d *MemoryDb) Delete(name string) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
 	delete(d.db, name)
 	return nil
 }
 
 func (d *MemoryDb) Find(name string) (*config.Programming, error) {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+
 	p, ok := d.db[name]
 	if !ok {
 		return nil, ErrNotFound
 	}
-	return &p, nil
+	ret := p.Clone()
+	return &ret, nil
+}
+
+func (d *MemoryDb) Update(name string, fn func(*config.Programming) error) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	stored, ok := d.db[name]
+	if !ok {
+		return ErrNotFound
+	}
+
+	// fn works on a copy, so the stored programming remains unchanged if it fails
+	p := stored.Clone()
+	if err := fn(&p); err != nil {
+		return err
+	}
+	if p.Name != name {
+		return errNameChanged
+	}
+
+	d.db[name] = p
+	return nil
 }
 
 func (d *MemoryDb) List() ([]config.Programming, error) {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+
 	var ret []config.Programming
 	for _, p := range d.db {
-		ret = append(ret, p)
+		ret = append(ret, p.Clone())
 	}
 	return ret, nil
 }
@@ -0,0 +1,80 @@
+package dbs
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+	"vcr/internal/config"
+)
+
+func testProgramming(t *testing.T) config.Programming {
+	t.Helper()
+	start := time.Date(2024, 3, 1, 20, 15, 0, 0, time.UTC)
+	until := start.Add(time.Hour)
+	p := config.Programming{
+		Name:   "news",
+		Url:    "https://example.com",
+		Date:   start,
+		Until:  &until,
+		Status: &config.RecordingStatus{},
+	}
+	if err := p.Status.Transition(config.StateScheduled, "added", start); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestDbs_IsolateCopies(t *testing.T) {
+	yamlDb, err := NewYamlDb(filepath.Join(t.TempDir(), "programmings.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, db := range map[string]Db{"memory": NewMemoryDb(), "yaml": yamlDb} {
+		t.Run(name, func(t *testing.T) {
+			added := testProgramming(t)
+			if err := db.Add(added); err != nil {
+				t.Fatal(err)
+			}
+			// changing the added programming must not change the stored one
+			added.Status.State = config.StateFailed
+			*added.Until = added.Until.Add(time.Hour)
+
+			found, err := db.Find("news")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if found.Status.State != config.StateScheduled || !found.Until.Equal(found.Date.Add(time.Hour)) {
+				t.Fatalf("Find() = %+v, changed through the added programming", found)
+			}
+
+			// neither do changes to returned programmings
+			found.Status.Transitions[0].Message = "changed"
+			listed, err := db.List()
+			if err != nil {
+				t.Fatal(err)
+			}
+			listed[0].Status.State = config.StateFailed
+
+			errUpdate := errors.New("update failed")
+			err = db.Update("news", func(p *config.Programming) error {
+				if err := p.Status.Transition(config.StateRecording, "started", time.Now()); err != nil {
+					return err
+				}
+				return errUpdate
+			})
+			if !errors.Is(err, errUpdate) {
+				t.Fatalf("Update() error = %v, want %v", err, errUpdate)
+			}
+
+			got, err := db.Find("news")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Status.State != config.StateScheduled || len(got.Status.Transitions) != 1 || got.Status.Transitions[0].Message != "added" {
+				t.Errorf("Find() status = %+v, want unchanged status", got.Status)
+			}
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package dbs
+
+import (
+	"sort"
+	"sync"
+	"time"
+	"vcr/internal/config"
+)
+
+type HistoryFilter struct {
+	Name string
+	From *time.Time
+	To   *time.Time
+}
+
+// Matches returns whether the run matches all the criteria of the filter.
+func (f HistoryFilter) Matches(run config.RecordingRun) bool {
+	if len(f.Name) > 0 && f.Name != run.Name {
+		return false
+	}
+	if f.From != nil && run.Start.Before(*f.From) {
+		return false
+	}
+	if f.To != nil && run.Start.After(*f.To) {
+		return false
+	}
+	return true
+}
+
+// History stores the runs of recordings. Implementations must be safe for concurrent use.
+type History interface {
+	Record(run config.RecordingRun) error
+	// Query returns all runs matching the filter, ordered by their start.
+	Query(filter HistoryFilter) ([]config.RecordingRun, error)
+}
+
+type MemoryHistory struct {
+	mutex sync.RWMutex
+	runs  []config.RecordingRun
+}
+
+func NewMemoryHistory() *MemoryHistory {
+	return &MemoryHistory{}
+}
+
+func (h *MemoryHistory) Record(run config.RecordingRun) error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.runs = append(h.runs, run)
+	return nil
+}
+
+func (h *MemoryHistory) Query(filter HistoryFilter) ([]config.RecordingRun, error) {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+
+	var ret []config.RecordingRun
+	for _, run := range h.runs {
+		if filter.Matches(run) {
+			ret = append(ret, run)
+		}
+	}
+
+	sort.SliceStable(ret, func(i, j int) bool {
+		return ret[i].Start.Before(ret[j].Start)
+	})
+	return ret, nil
+}
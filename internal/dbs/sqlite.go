@@ -0,0 +1,367 @@
+package dbs
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+	"vcr/internal/config"
+
+	_ "modernc.org/sqlite"
+)
+
+// migrations holds the schema changes of the sqlite database. Migrations are applied in order and must never be
+// altered once released, new changes have to be appended.
+var migrations = []string{
+	`CREATE TABLE programmings (
+		name  TEXT PRIMARY KEY NOT NULL,
+		url   TEXT NOT NULL,
+		start TEXT NOT NULL,
+		until TEXT
+	)`,
+	`ALTER TABLE programmings ADD COLUMN recurrence TEXT`,
+	`ALTER TABLE programmings ADD COLUMN status TEXT`,
+	`CREATE TABLE history (
+		id           INTEGER PRIMARY KEY AUTOINCREMENT,
+		name         TEXT NOT NULL,
+		container_id TEXT NOT NULL,
+		start        TEXT NOT NULL,
+		stop         TEXT,
+		exit_code    INTEGER,
+		output_file  TEXT NOT NULL,
+		output_size  INTEGER NOT NULL,
+		error        TEXT NOT NULL
+	)`,
+	`CREATE INDEX history_name_start ON history (name, start)`,
+	`ALTER TABLE programmings ADD COLUMN retry TEXT`,
+	`ALTER TABLE history ADD COLUMN attempt INTEGER NOT NULL DEFAULT 1`,
+	`ALTER TABLE programmings ADD COLUMN priority INTEGER NOT NULL DEFAULT 0`,
+	`ALTER TABLE programmings ADD COLUMN padding TEXT`,
+}
+
+const programmingColumns = `name, url, start, until, recurrence, status, retry, priority, padding`
+
+type SqliteDb struct {
+	db *sql.DB
+}
+
+func NewSqliteDb(path string) (*SqliteDb, error) {
+	if len(path) == 0 {
+		return nil, errors.New("empty path provided")
+	}
+
+	db, err := sql.Open("sqlite", fmt.Sprintf("file:%s?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)", path))
+	if err != nil {
+		return nil, err
+	}
+	// sqlite does not support concurrent writers
+	db.SetMaxOpenConns(1)
+
+	if err := migrate(db); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("could not migrate database: %w", err)
+	}
+
+	return &SqliteDb{db: db}, nil
+}
+
+func migrate(db *sql.DB) error {
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (version INTEGER NOT NULL)`); err != nil {
+		return err
+	}
+
+	var version int
+	if err := db.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&version); err != nil {
+		return err
+	}
+
+	for i := version; i < len(migrations); i++ {
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec(migrations[i]); err != nil {
+			_ = tx.Rollback()
+			return fmt.Errorf("migration %d failed: %w", i+1, err)
+		}
+
+		if _, err := tx.Exec(`INSERT INTO schema_migrations (version) VALUES (?)`, i+1); err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (d *SqliteDb) Close() error {
+	return d.db.Close()
+}
+
+type execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+func upsert(db execer, programming config.Programming) error {
+	recurrence, err := formatJson(programming.Recurrence)
+	if err != nil {
+		return err
+	}
+
+	status, err := formatJson(programming.Status)
+	if err != nil {
+		return err
+	}
+
+	retry, err := formatJson(programming.Retry)
+	if err != nil {
+		return err
+	}
+
+	padding, err := formatJson(programming.Padding)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec(`INSERT OR REPLACE INTO programmings (`+programmingColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		programming.Name, programming.Url, formatTime(programming.Date), formatOptionalTime(programming.Until),
+		recurrence, status, retry, programming.Priority, padding)
+	return err
+}
+
+func (d *SqliteDb) Add(programming config.Programming) error {
+	return upsert(d.db, programming)
+}
+
+func (d *SqliteDb) Update(name string, fn func(*config.Programming) error) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	row := tx.QueryRow(`SELECT `+programmingColumns+` FROM programmings WHERE name = ?`, name)
+	p, err := scanProgramming(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNotFound
+		}
+		return err
+	}
+
+	if err := fn(p); err != nil {
+		return err
+	}
+	if p.Name != name {
+		return errNameChanged
+	}
+
+	if err := upsert(tx, *p); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (d *SqliteDb) Delete(name string) error {
+	_, err := d.db.Exec(`DELETE FROM programmings WHERE name = ?`, name)
+	return err
+}
+
+func (d *SqliteDb) Find(name string) (*config.Programming, error) {
+	row := d.db.QueryRow(`SELECT `+programmingColumns+` FROM programmings WHERE name = ?`, name)
+	p, err := scanProgramming(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return p, nil
+}
+
+func (d *SqliteDb) List() ([]config.Programming, error) {
+	rows, err := d.db.Query(`SELECT ` + programmingColumns + ` FROM programmings`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ret []config.Programming
+	for rows.Next() {
+		p, err := scanProgramming(rows)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, *p)
+	}
+
+	return ret, rows.Err()
+}
+
+func (d *SqliteDb) Record(run config.RecordingRun) error {
+	var exitCode sql.NullInt64
+	if run.ExitCode != nil {
+		exitCode = sql.NullInt64{Int64: *run.ExitCode, Valid: true}
+	}
+
+	_, err := d.db.Exec(`INSERT INTO history (name, attempt, container_id, start, stop, exit_code, output_file, output_size, error) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		run.Name, run.Attempt, run.ContainerId, formatSortableTime(run.Start), formatOptionalSortableTime(run.Stop), exitCode, run.OutputFile,
+		run.OutputSize, run.Error)
+	return err
+}
+
+func (d *SqliteDb) Query(filter HistoryFilter) ([]config.RecordingRun, error) {
+	query := `SELECT name, attempt, container_id, start, stop, exit_code, output_file, output_size, error FROM history WHERE 1=1`
+	var args []any
+	if len(filter.Name) > 0 {
+		query += ` AND name = ?`
+		args = append(args, filter.Name)
+	}
+	if filter.From != nil {
+		query += ` AND start >= ?`
+		args = append(args, formatSortableTime(*filter.From))
+	}
+	if filter.To != nil {
+		query += ` AND start <= ?`
+		args = append(args, formatSortableTime(*filter.To))
+	}
+	query += ` ORDER BY start`
+
+	rows, err := d.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ret []config.RecordingRun
+	for rows.Next() {
+		var run config.RecordingRun
+		var start string
+		var stop sql.NullString
+		var exitCode sql.NullInt64
+		if err := rows.Scan(&run.Name, &run.Attempt, &run.ContainerId, &start, &stop, &exitCode, &run.OutputFile, &run.OutputSize, &run.Error); err != nil {
+			return nil, err
+		}
+
+		run.Start, err = time.Parse(time.RFC3339Nano, start)
+		if err != nil {
+			return nil, fmt.Errorf("invalid start of run %q: %w", run.Name, err)
+		}
+		if stop.Valid {
+			t, err := time.Parse(time.RFC3339Nano, stop.String)
+			if err != nil {
+				return nil, fmt.Errorf("invalid stop of run %q: %w", run.Name, err)
+			}
+			run.Stop = &t
+		}
+		if exitCode.Valid {
+			run.ExitCode = &exitCode.Int64
+		}
+		ret = append(ret, run)
+	}
+
+	return ret, rows.Err()
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanProgramming(row scanner) (*config.Programming, error) {
+	var p config.Programming
+	var start string
+	var until, recurrence, status, retry, padding sql.NullString
+	if err := row.Scan(&p.Name, &p.Url, &start, &until, &recurrence, &status, &retry, &p.Priority, &padding); err != nil {
+		return nil, err
+	}
+
+	var err error
+	p.Date, err = time.Parse(time.RFC3339Nano, start)
+	if err != nil {
+		return nil, fmt.Errorf("invalid start date for programming %q: %w", p.Name, err)
+	}
+
+	if until.Valid {
+		t, err := time.Parse(time.RFC3339Nano, until.String)
+		if err != nil {
+			return nil, fmt.Errorf("invalid end date for programming %q: %w", p.Name, err)
+		}
+		p.Until = &t
+	}
+
+	if recurrence.Valid {
+		p.Recurrence = &config.Recurrence{}
+		if err := json.Unmarshal([]byte(recurrence.String), p.Recurrence); err != nil {
+			return nil, fmt.Errorf("invalid recurrence for programming %q: %w", p.Name, err)
+		}
+	}
+
+	if status.Valid {
+		p.Status = &config.RecordingStatus{}
+		if err := json.Unmarshal([]byte(status.String), p.Status); err != nil {
+			return nil, fmt.Errorf("invalid status for programming %q: %w", p.Name, err)
+		}
+	}
+
+	if retry.Valid {
+		p.Retry = &config.RetryPolicy{}
+		if err := json.Unmarshal([]byte(retry.String), p.Retry); err != nil {
+			return nil, fmt.Errorf("invalid retry policy for programming %q: %w", p.Name, err)
+		}
+	}
+
+	if padding.Valid {
+		p.Padding = &config.Padding{}
+		if err := json.Unmarshal([]byte(padding.String), p.Padding); err != nil {
+			return nil, fmt.Errorf("invalid padding for programming %q: %w", p.Name, err)
+		}
+	}
+
+	return &p, nil
+}
+
+func formatTime(t time.Time) string {
+	return t.Format(time.RFC3339Nano)
+}
+
+// formatJson encodes optional values that are stored as json, nil values are stored as NULL.
+func formatJson[T any](val *T) (sql.NullString, error) {
+	if val == nil {
+		return sql.NullString{}, nil
+	}
+
+	data, err := json.Marshal(val)
+	if err != nil {
+		return sql.NullString{}, err
+	}
+	return sql.NullString{String: string(data), Valid: true}, nil
+}
+
+// sortableTimeFormat is a fixed width format that allows comparing timestamps lexicographically.
+const sortableTimeFormat = "2006-01-02T15:04:05.000000000Z"
+
+func formatSortableTime(t time.Time) string {
+	return t.UTC().Format(sortableTimeFormat)
+}
+
+func formatOptionalSortableTime(t *time.Time) sql.NullString {
+	if t == nil || t.IsZero() {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: formatSortableTime(*t), Valid: true}
+}
+
+func formatOptionalTime(t *time.Time) sql.NullString {
+	if t == nil || t.IsZero() {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: formatTime(*t), Valid: true}
+}
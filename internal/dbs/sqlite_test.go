@@ -0,0 +1,186 @@
+package dbs
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+	"vcr/internal/config"
+)
+
+func newTestSqliteDb(t *testing.T, path string) *SqliteDb {
+	t.Helper()
+	db, err := NewSqliteDb(path)
+	if err != nil {
+		t.Fatalf("NewSqliteDb() error = %v", err)
+	}
+	t.Cleanup(func() {
+		_ = db.Close()
+	})
+	return db
+}
+
+func schemaVersion(t *testing.T, db *sql.DB) int {
+	t.Helper()
+	var version int
+	if err := db.QueryRow(`SELECT MAX(version) FROM schema_migrations`).Scan(&version); err != nil {
+		t.Fatalf("could not read schema version: %v", err)
+	}
+	return version
+}
+
+func TestSqliteDb_Migrate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vcr.db")
+
+	db := newTestSqliteDb(t, path)
+	if got := schemaVersion(t, db.db); got != len(migrations) {
+		t.Errorf("schema version = %d, want %d", got, len(migrations))
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// reopening an up to date database must not apply migrations again
+	db = newTestSqliteDb(t, path)
+	var applied int
+	if err := db.db.QueryRow(`SELECT COUNT(*) FROM schema_migrations`).Scan(&applied); err != nil {
+		t.Fatal(err)
+	}
+	if applied != len(migrations) {
+		t.Errorf("applied migrations = %d, want %d", applied, len(migrations))
+	}
+}
+
+func TestSqliteDb_MigrateExistingData(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vcr.db")
+
+	// a database created by the first release only contains the first migration
+	raw, err := sql.Open("sqlite", fmt.Sprintf("file:%s", path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, stmt := range []string{
+		`CREATE TABLE schema_migrations (version INTEGER NOT NULL)`,
+		migrations[0],
+		`INSERT INTO schema_migrations (version) VALUES (1)`,
+		`INSERT INTO programmings (name, url, start) VALUES ('news', 'https://example.com', '2024-01-01T20:00:00Z')`,
+	} {
+		if _, err := raw.Exec(stmt); err != nil {
+			t.Fatalf("could not prepare database: %v", err)
+		}
+	}
+	if err := raw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	db := newTestSqliteDb(t, path)
+	if got := schemaVersion(t, db.db); got != len(migrations) {
+		t.Errorf("schema version = %d, want %d", got, len(migrations))
+	}
+
+	p, err := db.Find("news")
+	if err != nil {
+		t.Fatalf("Find() error = %v", err)
+	}
+	if p.Url != "https://example.com" || p.Priority != 0 || p.Status != nil || p.Padding != nil {
+		t.Errorf("Find() = %+v, want migrated programming with defaults", p)
+	}
+}
+
+func TestSqliteDb_NotFound(t *testing.T) {
+	db := newTestSqliteDb(t, filepath.Join(t.TempDir(), "vcr.db"))
+
+	if _, err := db.Find("missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Find() error = %v, want %v", err, ErrNotFound)
+	}
+
+	err := db.Update("missing", func(p *config.Programming) error {
+		return nil
+	})
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("Update() error = %v, want %v", err, ErrNotFound)
+	}
+
+	if err := db.Delete("missing"); err != nil {
+		t.Errorf("Delete() error = %v, want nil", err)
+	}
+}
+
+func TestSqliteDb_RoundTrip(t *testing.T) {
+	db := newTestSqliteDb(t, filepath.Join(t.TempDir(), "vcr.db"))
+
+	start := time.Date(2024, 3, 1, 20, 15, 0, 0, time.UTC)
+	until := start.Add(time.Hour)
+	want := config.Programming{
+		Name:  "news",
+		Url:   "https://example.com",
+		Date:  start,
+		Until: &until,
+		Recurrence: &config.Recurrence{
+			Cron:     "15 20 * * *",
+			Duration: time.Hour,
+		},
+		Priority: 2,
+		Padding:  &config.Padding{Before: time.Minute, After: 5 * time.Minute},
+		Status:   &config.RecordingStatus{},
+	}
+	if err := want.Status.Transition(config.StateScheduled, "added", start); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.Add(want); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+
+	got, err := db.Find("news")
+	if err != nil {
+		t.Fatalf("Find() error = %v", err)
+	}
+	if !got.Date.Equal(want.Date) || got.Until == nil || !got.Until.Equal(until) {
+		t.Errorf("Find() times = %v - %v, want %v - %v", got.Date, got.Until, want.Date, until)
+	}
+	if got.Recurrence == nil || got.Recurrence.Cron != want.Recurrence.Cron || got.Recurrence.Duration != time.Hour {
+		t.Errorf("Find() recurrence = %+v, want %+v", got.Recurrence, want.Recurrence)
+	}
+	if got.Priority != 2 || got.Padding == nil || *got.Padding != *want.Padding {
+		t.Errorf("Find() priority = %d, padding = %+v", got.Priority, got.Padding)
+	}
+	if got.Status == nil || got.Status.State != config.StateScheduled || len(got.Status.Transitions) != 1 {
+		t.Errorf("Find() status = %+v", got.Status)
+	}
+}
+
+func TestSqliteDb_UpdateError(t *testing.T) {
+	db := newTestSqliteDb(t, filepath.Join(t.TempDir(), "vcr.db"))
+
+	if err := db.Add(config.Programming{Name: "news", Url: "https://example.com", Date: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+
+	errUpdate := errors.New("update failed")
+	err := db.Update("news", func(p *config.Programming) error {
+		p.Url = "https://example.org"
+		return errUpdate
+	})
+	if !errors.Is(err, errUpdate) {
+		t.Errorf("Update() error = %v, want %v", err, errUpdate)
+	}
+
+	err = db.Update("news", func(p *config.Programming) error {
+		p.Name = "renamed"
+		return nil
+	})
+	if !errors.Is(err, errNameChanged) {
+		t.Errorf("Update() error = %v, want %v", err, errNameChanged)
+	}
+
+	got, err := db.Find("news")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Url != "https://example.com" {
+		t.Errorf("Url = %q after failed updates, want unchanged", got.Url)
+	}
+}
@@ -0,0 +1,354 @@
+package dbs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"vcr/internal/config"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/rs/zerolog/log"
+	"gopkg.in/yaml.v3"
+)
+
+type yamlFile struct {
+	Programmings []config.Programming `yaml:"programmings"`
+}
+
+// yamlStateFile holds the status of the recordings of all programmings, keyed by the name of the programming.
+type yamlStateFile struct {
+	Statuses map[string]*config.RecordingStatus `yaml:"statuses"`
+}
+
+// YamlDb stores programmings in a yaml file. Changes made through the api are written back to the file atomically,
+// external changes to the file are picked up by Watch. The status of recordings changes while recording and is kept in
+// a sidecar state file next to it, so the file that's edited by hand is only written if a programming changes.
+type YamlDb struct {
+	path      string
+	statePath string
+
+	mutex sync.RWMutex
+	// programmings are stored without their status, it's kept in statuses
+	programmings map[string]config.Programming
+	statuses     map[string]*config.RecordingStatus
+}
+
+func NewYamlDb(path string) (*YamlDb, error) {
+	if len(path) == 0 {
+		return nil, errors.New("empty path provided")
+	}
+
+	db := &YamlDb{
+		path:         path,
+		statePath:    statePath(path),
+		programmings: map[string]config.Programming{},
+		statuses:     map[string]*config.RecordingStatus{},
+	}
+
+	if err := db.loadState(); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	if err := db.load(); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	return db, nil
+}
+
+// statePath returns the path of the sidecar state file, e.g. "programmings.state.yaml" for "programmings.yaml".
+func statePath(path string) string {
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + ".state" + ext
+}
+
+func (d *YamlDb) loadState() error {
+	data, err := os.ReadFile(d.statePath)
+	if err != nil {
+		return err
+	}
+
+	var file yamlStateFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("could not parse %s: %w", d.statePath, err)
+	}
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	for name, status := range file.Statuses {
+		if status != nil {
+			d.statuses[name] = status
+		}
+	}
+	return nil
+}
+
+func (d *YamlDb) load() error {
+	data, err := os.ReadFile(d.path)
+	if err != nil {
+		return err
+	}
+
+	var file yamlFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("could not parse %s: %w", d.path, err)
+	}
+
+	programmings := make(map[string]config.Programming, len(file.Programmings))
+	for _, p := range file.Programmings {
+		if len(p.Name) == 0 {
+			return fmt.Errorf("could not parse %s: programming without name", d.path)
+		}
+		if _, found := programmings[p.Name]; found {
+			return fmt.Errorf("could not parse %s: duplicate programming %q", d.path, p.Name)
+		}
+		if err := p.Validate(); err != nil {
+			return fmt.Errorf("invalid programming %q in %s: %w", p.Name, d.path, err)
+		}
+		programmings[p.Name] = p
+	}
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	for name, p := range programmings {
+		// files written by previous versions contain the status, it's taken over unless the state file knows better
+		if p.Status != nil {
+			if _, found := d.statuses[name]; !found {
+				d.statuses[name] = p.Status
+			}
+			p.Status = nil
+			programmings[name] = p
+		}
+	}
+	d.programmings = programmings
+	return nil
+}
+
+// withStatus returns a copy of the programming including its status. The caller must hold the lock.
+func (d *YamlDb) withStatus(p config.Programming) config.Programming {
+	p.Status = d.statuses[p.Name]
+	return p.Clone()
+}
+
+// setStatus stores the status of the programming, nil removes it. The caller must hold the lock.
+func (d *YamlDb) setStatus(name string, status *config.RecordingStatus) {
+	if status == nil {
+		delete(d.statuses, name)
+		return
+	}
+	d.statuses[name] = status
+}
+
+// persist writes the programmings without their status to the file. The caller must hold the lock.
+func (d *YamlDb) persist() error {
+	file := yamlFile{
+		Programmings: make([]config.Programming, 0, len(d.programmings)),
+	}
+	for _, p := range d.programmings {
+		file.Programmings = append(file.Programmings, p)
+	}
+	sort.Slice(file.Programmings, func(i, j int) bool {
+		return file.Programmings[i].Name < file.Programmings[j].Name
+	})
+
+	return writeYaml(d.path, file)
+}
+
+// persistState writes the status of all programmings to the state file. The caller must hold the lock.
+func (d *YamlDb) persistState() error {
+	file := yamlStateFile{
+		Statuses: make(map[string]*config.RecordingStatus, len(d.statuses)),
+	}
+	for name, status := range d.statuses {
+		if _, found := d.programmings[name]; found {
+			file.Statuses[name] = status
+		}
+	}
+
+	return writeYaml(d.statePath, file)
+}
+
+// writeYaml writes the value to a temporary file that replaces the file at path afterwards.
+func writeYaml(path string, value any) error {
+	data, err := yaml.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = os.Remove(tmp.Name())
+	}()
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// Watch reloads the file whenever it's changed on disk. It blocks until the context is cancelled.
+func (d *YamlDb) Watch(ctx context.Context, wg *sync.WaitGroup, onChange func()) error {
+	wg.Add(1)
+	defer wg.Done()
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+
+	// watch the directory instead of the file itself, as editors and our own atomic writes replace the file
+	if err := watcher.Add(filepath.Dir(d.path)); err != nil {
+		return err
+	}
+
+	target := filepath.Clean(d.path)
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if filepath.Clean(event.Name) != target || !event.Has(fsnotify.Write|fsnotify.Create) {
+				continue
+			}
+			if err := d.load(); err != nil {
+				log.Error().Err(err).Msg("could not reload programmings, keeping previous state")
+				continue
+			}
+			log.Info().Msgf("Reloaded programmings from %s", d.path)
+			if onChange != nil {
+				onChange()
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			log.Error().Err(err).Msg("error while watching programmings file")
+		}
+	}
+}
+
+// write stores the programming and its status and persists the files that changed. The previous state is restored if
+// persisting fails. The caller must hold the lock.
+func (d *YamlDb) write(name string, p *config.Programming) error {
+	prev, found := d.programmings[name]
+	prevStatus := d.statuses[name]
+
+	var status *config.RecordingStatus
+	if p == nil {
+		delete(d.programmings, name)
+	} else {
+		def := *p
+		def.Status = nil
+		status = p.Status
+		d.programmings[name] = def
+	}
+	d.setStatus(name, status)
+
+	restore := func() {
+		if found {
+			d.programmings[name] = prev
+		} else {
+			delete(d.programmings, name)
+		}
+		d.setStatus(name, prevStatus)
+	}
+
+	if !found || p == nil || !reflect.DeepEqual(d.programmings[name], prev) {
+		if err := d.persist(); err != nil {
+			restore()
+			return err
+		}
+	}
+
+	if !reflect.DeepEqual(status, prevStatus) {
+		if err := d.persistState(); err != nil {
+			restore()
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *YamlDb) Add(programming config.Programming) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	p := programming.Clone()
+	return d.write(p.Name, &p)
+}
+
+func (d *YamlDb) Delete(name string) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if _, found := d.programmings[name]; !found {
+		return nil
+	}
+	return d.write(name, nil)
+}
+
+func (d *YamlDb) Update(name string, fn func(*config.Programming) error) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	prev, found := d.programmings[name]
+	if !found {
+		return ErrNotFound
+	}
+
+	// fn works on a copy, so the stored programming remains unchanged if it fails
+	p := d.withStatus(prev)
+	if err := fn(&p); err != nil {
+		return err
+	}
+	if p.Name != name {
+		return errNameChanged
+	}
+
+	return d.write(name, &p)
+}
+
+func (d *YamlDb) Find(name string) (*config.Programming, error) {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+
+	p, ok := d.programmings[name]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	ret := d.withStatus(p)
+	return &ret, nil
+}
+
+func (d *YamlDb) List() ([]config.Programming, error) {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+
+	var ret []config.Programming
+	for _, p := range d.programmings {
+		ret = append(ret, d.withStatus(p))
+	}
+	return ret, nil
+}
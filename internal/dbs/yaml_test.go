@@ -0,0 +1,146 @@
+package dbs
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"vcr/internal/config"
+)
+
+const newsProgramming = `  - name: news # daily news
+    url: https://example.com/news
+    start: 2024-03-01T20:00:00Z
+    end: 2024-03-01T20:15:00Z
+`
+
+const handEditedFile = "# programmings maintained in git\nprogrammings:\n" + newsProgramming
+
+func TestYamlDb_StatusInStateFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "programmings.yaml")
+	if err := os.WriteFile(path, []byte(handEditedFile), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := NewYamlDb(path)
+	if err != nil {
+		t.Fatalf("NewYamlDb() error = %v", err)
+	}
+
+	err = db.Update("news", func(p *config.Programming) error {
+		p.Status = &config.RecordingStatus{}
+		return p.Status.Transition(config.StateScheduled, "scheduled", time.Now())
+	})
+	if err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != handEditedFile {
+		t.Errorf("programmings file changed by status update:\n%s", data)
+	}
+
+	state, err := os.ReadFile(filepath.Join(filepath.Dir(path), "programmings.state.yaml"))
+	if err != nil {
+		t.Fatalf("could not read state file: %v", err)
+	}
+	if !strings.Contains(string(state), "news:") || !strings.Contains(string(state), "state: scheduled") {
+		t.Errorf("state file does not contain the status:\n%s", state)
+	}
+
+	// the status survives a restart
+	db, err = NewYamlDb(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := db.Find("news")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Status == nil || p.Status.State != config.StateScheduled {
+		t.Errorf("Find() status = %+v, want scheduled", p.Status)
+	}
+}
+
+func TestYamlDb_MigrateStatusFromFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "programmings.yaml")
+	legacy := handEditedFile + `    status:
+      state: completed
+`
+	if err := os.WriteFile(path, []byte(legacy), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := NewYamlDb(path)
+	if err != nil {
+		t.Fatalf("NewYamlDb() error = %v", err)
+	}
+
+	p, err := db.Find("news")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Status == nil || p.Status.State != config.StateCompleted {
+		t.Fatalf("Find() status = %+v, want status of the file", p.Status)
+	}
+
+	// changing the programming writes it without its status
+	err = db.Update("news", func(p *config.Programming) error {
+		p.Url = "https://example.org/news"
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "status") || !strings.Contains(string(data), "https://example.org/news") {
+		t.Errorf("programmings file = \n%s", data)
+	}
+}
+
+func TestYamlDb_RejectInvalidProgramming(t *testing.T) {
+	tests := []struct {
+		name        string
+		programming string
+	}{
+		{name: "missing url", programming: "  - name: news\n    start: 2024-03-01T20:00:00Z\n"},
+		{name: "invalid cron", programming: newsProgramming + "    recurrence:\n      cron: \"61 20 * * *\"\n      duration: 15m\n"},
+		{name: "invalid retry", programming: newsProgramming + "    retry:\n      max_attempts: -1\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "programmings.yaml")
+			if err := os.WriteFile(path, []byte(handEditedFile), 0644); err != nil {
+				t.Fatal(err)
+			}
+			db, err := NewYamlDb(path)
+			if err != nil {
+				t.Fatalf("NewYamlDb() error = %v", err)
+			}
+
+			invalid := "programmings:\n" + tt.programming
+			if err := os.WriteFile(path, []byte(invalid), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if err := db.load(); err == nil {
+				t.Fatal("load() error = nil, want invalid programming to be rejected")
+			}
+			// the previous state is kept
+			p, err := db.Find("news")
+			if err != nil || p.Url != "https://example.com/news" || p.Recurrence != nil || p.Retry != nil {
+				t.Errorf("Find() = %+v, %v, want programming of the previous file", p, err)
+			}
+
+			if _, err := NewYamlDb(path); err == nil {
+				t.Error("NewYamlDb() error = nil, want invalid programming to be rejected")
+			}
+		})
+	}
+}
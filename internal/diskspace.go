@@ -0,0 +1,68 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"time"
+	"vcr/internal/config"
+
+	"github.com/rs/zerolog/log"
+)
+
+var errInsufficientDiskSpace = errors.New("insufficient disk space")
+
+// DiskGuard checks the free space of the directory recordings are written to.
+type DiskGuard struct {
+	path     string
+	minFree  uint64
+	policy   string
+	interval time.Duration
+}
+
+// NewDiskGuard returns a guard for the given path. The guard is disabled if the path is empty or no minimum of free
+// space is configured.
+func NewDiskGuard(path string, conf config.DiskConfig) (*DiskGuard, error) {
+	if conf.Policy != config.DiskPolicyRefuse && conf.Policy != config.DiskPolicyWarn {
+		return nil, fmt.Errorf("unknown disk policy %q", conf.Policy)
+	}
+
+	if conf.CheckInterval <= 0 {
+		return nil, errors.New("check interval must be positive")
+	}
+
+	return &DiskGuard{
+		path:     path,
+		minFree:  conf.MinFreeBytes,
+		policy:   conf.Policy,
+		interval: conf.CheckInterval,
+	}, nil
+}
+
+func (g *DiskGuard) enabled() bool {
+	return len(g.path) > 0 && g.minFree > 0
+}
+
+// check returns an error if the free space is below the minimum and the policy refuses recordings. Free space that
+// can not be determined does not prevent recordings.
+func (g *DiskGuard) check() error {
+	if !g.enabled() {
+		return nil
+	}
+
+	free, err := freeSpace(g.path)
+	if err != nil {
+		log.Warn().Err(err).Msgf("could not determine free space of %s", g.path)
+		return nil
+	}
+
+	if free >= g.minFree {
+		return nil
+	}
+
+	err = fmt.Errorf("%w: %d bytes free on %s, %d bytes required", errInsufficientDiskSpace, free, g.path, g.minFree)
+	if g.policy == config.DiskPolicyWarn {
+		log.Warn().Err(err).Msg("Running out of disk space")
+		return nil
+	}
+	return err
+}
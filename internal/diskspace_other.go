@@ -0,0 +1,9 @@
+//go:build !unix
+
+package internal
+
+import "errors"
+
+func freeSpace(path string) (uint64, error) {
+	return 0, errors.New("determining free space is not supported on this platform")
+}
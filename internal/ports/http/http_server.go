@@ -51,6 +51,18 @@ func New(address string, vcr *internal.Vcr, opts ...WebhookOpts) (*Webhook, erro
 	return w, errs
 }
 
+// WithTLS serves the api via https with the given certificate and key.
+func WithTLS(certFile, keyFile string) WebhookOpts {
+	return func(w *Webhook) error {
+		if len(certFile) == 0 || len(keyFile) == 0 {
+			return errors.New("both certificate and key need to be provided")
+		}
+		w.certFile = certFile
+		w.keyFile = keyFile
+		return nil
+	}
+}
+
 func (w *Webhook) IsTLSConfigured() bool {
 	return len(w.certFile) > 0 && len(w.keyFile) > 0
 }
@@ -78,6 +90,8 @@ func (s *Webhook) add(w http.ResponseWriter, r *http.Request) {
 	if err := s.vcr.AddProgramming(p); err != nil {
 		if errors.Is(err, internal.ErrValidationError) {
 			http.Error(w, err.Error(), http.StatusBadRequest)
+		} else if errors.Is(err, internal.ErrConflict) || errors.Is(err, internal.ErrAlreadyExists) {
+			http.Error(w, err.Error(), http.StatusConflict)
 		} else {
 			http.Error(w, "Ops", http.StatusInternalServerError)
 		}
@@ -87,6 +101,42 @@ func (s *Webhook) add(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 }
 
+func (s *Webhook) update(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		log.Error().Err(err).Msg("can not read from body")
+		http.Error(w, "Internal server error", 500)
+		return
+	}
+	_ = r.Body.Close()
+
+	p := ports.UpdateProgrammingRequest{}
+	if err := json.Unmarshal(data, &p); err != nil {
+		http.Error(w, "Can not marshal json", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.vcr.UpdateProgramming(p); err != nil {
+		if errors.Is(err, internal.ErrValidationError) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		} else if errors.Is(err, internal.ErrNotFound) {
+			http.Error(w, "Not found", http.StatusNotFound)
+		} else if errors.Is(err, internal.ErrConflict) {
+			http.Error(w, err.Error(), http.StatusConflict)
+		} else {
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
 func (s *Webhook) delete(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -167,15 +217,71 @@ func (s *Webhook) list(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(p)
 }
 
+func (s *Webhook) history(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		log.Error().Err(err).Msg("can not read from body")
+		http.Error(w, "Internal server error", 500)
+		return
+	}
+	_ = r.Body.Close()
+
+	// all filters are optional, so an empty body is valid
+	p := ports.HistoryRequest{}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &p); err != nil {
+			http.Error(w, "Can not marshal json", http.StatusBadRequest)
+			return
+		}
+	}
+
+	runs, err := s.vcr.GetHistory(p)
+	if err != nil {
+		if errors.Is(err, internal.ErrValidationError) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		} else {
+			log.Error().Err(err).Msg("can not query history")
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	json.NewEncoder(w).Encode(runs)
+}
+
+func (s *Webhook) retention(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	candidates, err := s.vcr.GetRetentionCandidates()
+	if err != nil {
+		log.Error().Err(err).Msg("can not plan retention")
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(candidates)
+}
+
 func (w *Webhook) Listen(ctx context.Context, wg *sync.WaitGroup) error {
 	wg.Add(1)
 	defer wg.Done()
 
 	mux := http.NewServeMux()
 	mux.HandleFunc("/add", w.add)
+	mux.HandleFunc("/update", w.update)
 	mux.HandleFunc("/delete", w.delete)
 	mux.HandleFunc("/list", w.list)
 	mux.HandleFunc("/get", w.get)
+	mux.HandleFunc("/history", w.history)
+	mux.HandleFunc("/retention", w.retention)
 
 	server := http.Server{
 		Addr:              w.address,
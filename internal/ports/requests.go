@@ -1,8 +1,10 @@
 package ports
 
 import (
+	"errors"
 	"time"
 	"vcr/internal/config"
+	"vcr/internal/dbs"
 )
 
 type DeleteProgrammingRequest struct {
@@ -13,55 +15,225 @@ type GetProgrammingRequest struct {
 	Name string `json:"name"`
 }
 
+// HistoryRequest filters the runs of recordings. All fields are optional, From and To refer to the start of a run.
+type HistoryRequest struct {
+	Name string `json:"name,omitempty"`
+	From string `json:"from,omitempty"`
+	To   string `json:"to,omitempty"`
+}
+
+func (r HistoryRequest) ToFilter(loc *time.Location) (dbs.HistoryFilter, error) {
+	filter := dbs.HistoryFilter{
+		Name: r.Name,
+	}
+
+	now := time.Now()
+	if len(r.From) > 0 {
+		from, err := parseTime(r.From, now, loc)
+		if err != nil {
+			return dbs.HistoryFilter{}, err
+		}
+		filter.From = &from
+	}
+
+	if len(r.To) > 0 {
+		to, err := parseTime(r.To, now, loc)
+		if err != nil {
+			return dbs.HistoryFilter{}, err
+		}
+		filter.To = &to
+	}
+
+	return filter, nil
+}
+
+// UpdateProgrammingRequest replaces an existing programming.
+type UpdateProgrammingRequest struct {
+	AddProgrammingRequest
+}
+
 type AddProgrammingRequest struct {
 	Url   string `json:"url" yaml:"url" validate:"required,url"`
 	Name  string `json:"name" validate:"required"`
 	Date  string `json:"start" validate:"required"`
 	Until string `json:"end,omitempty" validate:"omitempty"`
+	// Duration is an alternative to Until for programmings without a recurrence.
+	Duration string `json:"duration,omitempty" validate:"excluded_with=Until Recurrence"`
+	// Timezone is the IANA name of the location that start and end are interpreted in if they carry no offset.
+	Timezone string `json:"timezone,omitempty" validate:"omitempty,timezone"`
+
+	Recurrence *RecurrenceRequest `json:"recurrence,omitempty"`
+	Retry      *RetryRequest      `json:"retry,omitempty"`
+	Priority   int                `json:"priority,omitempty"`
+	Padding    *PaddingRequest    `json:"padding,omitempty"`
 }
 
-func (r AddProgrammingRequest) ToProgramming() (config.Programming, error) {
-	p := config.Programming{
-		Url:   r.Url,
-		Name:  r.Name,
-		Date:  time.Time{},
-		Until: nil,
+// PaddingRequest extends the recording beyond start and end of the programming, both fields are durations.
+type PaddingRequest struct {
+	Before string `json:"before,omitempty"`
+	After  string `json:"after,omitempty"`
+}
+
+func (r PaddingRequest) ToPadding() (*config.Padding, error) {
+	padding := &config.Padding{}
+
+	var err error
+	if len(r.Before) > 0 {
+		padding.Before, err = time.ParseDuration(r.Before)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(r.After) > 0 {
+		padding.After, err = time.ParseDuration(r.After)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if padding.Before < 0 || padding.After < 0 {
+		return nil, errors.New("padding must not be negative")
+	}
+	return padding, nil
+}
+
+type RetryRequest struct {
+	MaxAttempts    int    `json:"max_attempts" validate:"required,min=1"`
+	InitialBackoff string `json:"initial_backoff,omitempty"`
+	MaxBackoff     string `json:"max_backoff,omitempty"`
+}
+
+func (r RetryRequest) ToRetryPolicy() (*config.RetryPolicy, error) {
+	policy := &config.RetryPolicy{
+		MaxAttempts:    r.MaxAttempts,
+		InitialBackoff: config.DefaultRetryInitialBackoff,
+		MaxBackoff:     config.DefaultRetryMaxBackoff,
 	}
 
 	var err error
-	p.Date, err = parseTime(r.Date, time.Now())
+	if len(r.InitialBackoff) > 0 {
+		policy.InitialBackoff, err = time.ParseDuration(r.InitialBackoff)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(r.MaxBackoff) > 0 {
+		policy.MaxBackoff, err = time.ParseDuration(r.MaxBackoff)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return policy, policy.Validate()
+}
+
+type RecurrenceRequest struct {
+	Cron       string   `json:"cron,omitempty" validate:"required_without=RRule,excluded_with=RRule"`
+	RRule      string   `json:"rrule,omitempty" validate:"required_without=Cron"`
+	Duration   string   `json:"duration" validate:"required"`
+	Timezone   string   `json:"timezone,omitempty" validate:"omitempty,timezone"`
+	Exceptions []string `json:"exceptions,omitempty" validate:"omitempty,dive,datetime=2006-01-02"`
+}
+
+func (r RecurrenceRequest) ToRecurrence(defaultLoc *time.Location) (*config.Recurrence, error) {
+	duration, err := time.ParseDuration(r.Duration)
 	if err != nil {
-		return config.Programming{}, err
+		return nil, err
 	}
 
-	if len(r.Until) == 0 {
-		return p, nil
+	timezone := r.Timezone
+	if len(timezone) == 0 && defaultLoc != nil && defaultLoc != time.Local {
+		timezone = defaultLoc.String()
+	}
+
+	recurrence := &config.Recurrence{
+		Cron:       r.Cron,
+		RRule:      r.RRule,
+		Duration:   duration,
+		Timezone:   timezone,
+		Exceptions: r.Exceptions,
+	}
+
+	return recurrence, recurrence.Validate()
+}
+
+// ToProgramming converts the request to a programming. Times without an explicit offset or timezone are interpreted
+// in defaultLoc.
+func (r AddProgrammingRequest) ToProgramming(defaultLoc *time.Location) (config.Programming, error) {
+	loc := defaultLoc
+	if len(r.Timezone) > 0 {
+		var err error
+		loc, err = time.LoadLocation(r.Timezone)
+		if err != nil {
+			return config.Programming{}, err
+		}
+	}
+	if loc == nil {
+		loc = time.Local
 	}
 
-	until, err := parseTime(r.Until, p.Date)
+	p := config.Programming{
+		Url:      r.Url,
+		Name:     r.Name,
+		Date:     time.Time{},
+		Until:    nil,
+		Priority: r.Priority,
+	}
+
+	var err error
+	p.Date, err = parseTime(r.Date, time.Now(), loc)
 	if err != nil {
 		return config.Programming{}, err
 	}
-	p.Until = &until
 
-	return p, nil
-}
+	if r.Recurrence != nil {
+		p.Recurrence, err = r.Recurrence.ToRecurrence(loc)
+		if err != nil {
+			return config.Programming{}, err
+		}
+	}
+
+	if r.Retry != nil {
+		p.Retry, err = r.Retry.ToRetryPolicy()
+		if err != nil {
+			return config.Programming{}, err
+		}
+	}
+
+	if r.Padding != nil {
+		p.Padding, err = r.Padding.ToPadding()
+		if err != nil {
+			return config.Programming{}, err
+		}
+	}
 
-func parseTime(input string, relativeBase time.Time) (time.Time, error) {
-	parsed, err := time.Parse("2006-01-02T15:04:05", input)
-	if err == nil {
-		return parsed, nil
+	if len(r.Duration) > 0 {
+		duration, err := time.ParseDuration(r.Duration)
+		if err != nil {
+			return config.Programming{}, err
+		}
+		if duration <= 0 {
+			return config.Programming{}, errors.New("duration must be positive")
+		}
+		until := p.Date.Add(duration)
+		p.Until = &until
+		return p, nil
 	}
 
-	parsed, err = time.Parse("15:04:05", input)
-	if err == nil {
-		return parsed, nil
+	if len(r.Until) == 0 {
+		return p, nil
 	}
 
-	duration, err := time.ParseDuration(input)
-	if err == nil {
-		return relativeBase.Add(duration), nil
+	until, err := parseTime(r.Until, p.Date, loc)
+	if err != nil {
+		return config.Programming{}, err
+	}
+	if !until.After(p.Date) {
+		return config.Programming{}, errors.New("end must be after the start")
 	}
+	p.Until = &until
 
-	return time.Time{}, err
+	return p, nil
 }
@@ -0,0 +1,47 @@
+package ports
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAddProgrammingRequest_ToProgramming(t *testing.T) {
+	tests := []struct {
+		name      string
+		until     string
+		duration  string
+		wantUntil time.Time
+		wantErr   bool
+	}{
+		{name: "without end"},
+		{name: "end after start", until: "2024-03-01T21:00:00Z", wantUntil: time.Date(2024, 3, 1, 21, 0, 0, 0, time.UTC)},
+		{name: "end before start", until: "2024-03-01T19:00:00Z", wantErr: true},
+		{name: "end at start", until: "2024-03-01T20:00:00Z", wantErr: true},
+		{name: "local time before start", until: "2024-03-01 19:30", wantErr: true},
+		{name: "time of day before start", until: "19:30", wantUntil: time.Date(2024, 3, 2, 19, 30, 0, 0, time.UTC)},
+		{name: "duration", duration: "15m", wantUntil: time.Date(2024, 3, 1, 20, 15, 0, 0, time.UTC)},
+		{name: "negative duration", duration: "-15m", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := AddProgrammingRequest{
+				Url:      "https://example.com/news",
+				Name:     "news",
+				Date:     "2024-03-01T20:00:00Z",
+				Until:    tt.until,
+				Duration: tt.duration,
+			}
+
+			p, err := req.ToProgramming(time.UTC)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ToProgramming() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if tt.wantUntil.IsZero() != (p.Until == nil) || (p.Until != nil && !p.Until.Equal(tt.wantUntil)) {
+				t.Errorf("ToProgramming() until = %v, want %v", p.Until, tt.wantUntil)
+			}
+		})
+	}
+}
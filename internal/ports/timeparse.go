@@ -0,0 +1,126 @@
+package ports
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+var absoluteLayouts = []string{
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+}
+
+var clockLayouts = []string{
+	"15:04:05",
+	"15:04",
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun":       time.Sunday,
+	"sunday":    time.Sunday,
+	"mon":       time.Monday,
+	"monday":    time.Monday,
+	"tue":       time.Tuesday,
+	"tuesday":   time.Tuesday,
+	"wed":       time.Wednesday,
+	"wednesday": time.Wednesday,
+	"thu":       time.Thursday,
+	"thursday":  time.Thursday,
+	"fri":       time.Friday,
+	"friday":    time.Friday,
+	"sat":       time.Saturday,
+	"saturday":  time.Saturday,
+}
+
+// parseTime parses the input in the given location. It accepts
+//   - RFC3339 timestamps including an offset, the location is ignored
+//   - local timestamps such as "2006-01-02T15:04:05" or "2006-01-02 15:04"
+//   - a time of day such as "20:15", which resolves to its next occurrence after relativeBase
+//   - a day followed by a time of day such as "tomorrow 20:15" or "fri 21:00"
+//   - a duration such as "90m", which is added to relativeBase
+func parseTime(input string, relativeBase time.Time, loc *time.Location) (time.Time, error) {
+	input = strings.TrimSpace(input)
+
+	parsed, err := time.Parse(time.RFC3339, input)
+	if err == nil {
+		return parsed, nil
+	}
+
+	for _, layout := range absoluteLayouts {
+		parsed, err := time.ParseInLocation(layout, input, loc)
+		if err == nil {
+			return parsed, nil
+		}
+	}
+
+	clock, err := parseClock(input)
+	if err == nil {
+		return nextTimeOfDay(relativeBase.In(loc), clock), nil
+	}
+
+	fields := strings.Fields(strings.ToLower(input))
+	if len(fields) == 2 {
+		clock, err := parseClock(fields[1])
+		if err == nil {
+			return parseDay(fields[0], relativeBase.In(loc), clock)
+		}
+	}
+
+	duration, err := time.ParseDuration(input)
+	if err == nil {
+		return relativeBase.Add(duration), nil
+	}
+
+	return time.Time{}, fmt.Errorf("can not parse time %q", input)
+}
+
+func parseClock(input string) (time.Time, error) {
+	var err error
+	for _, layout := range clockLayouts {
+		var parsed time.Time
+		parsed, err = time.Parse(layout, input)
+		if err == nil {
+			return parsed, nil
+		}
+	}
+	return time.Time{}, err
+}
+
+func parseDay(day string, base time.Time, clock time.Time) (time.Time, error) {
+	switch day {
+	case "today":
+		return atTimeOfDay(base, clock, 0), nil
+	case "tomorrow":
+		return atTimeOfDay(base, clock, 1), nil
+	}
+
+	weekday, ok := weekdays[day]
+	if !ok {
+		return time.Time{}, fmt.Errorf("unknown day %q", day)
+	}
+
+	offset := (int(weekday) - int(base.Weekday()) + 7) % 7
+	ret := atTimeOfDay(base, clock, offset)
+	if !ret.After(base) {
+		ret = atTimeOfDay(base, clock, offset+7)
+	}
+	return ret, nil
+}
+
+// nextTimeOfDay returns the first point in time after base that matches the time of day of clock.
+func nextTimeOfDay(base time.Time, clock time.Time) time.Time {
+	ret := atTimeOfDay(base, clock, 0)
+	if !ret.After(base) {
+		ret = atTimeOfDay(base, clock, 1)
+	}
+	return ret
+}
+
+// atTimeOfDay returns the time of day of clock on the date of base, shifted by the given amount of days. Adding days
+// to the date instead of multiples of 24h keeps the wall clock time across DST changes.
+func atTimeOfDay(base time.Time, clock time.Time, offsetDays int) time.Time {
+	return time.Date(base.Year(), base.Month(), base.Day()+offsetDays, clock.Hour(), clock.Minute(), clock.Second(), 0, base.Location())
+}
@@ -0,0 +1,161 @@
+package ports
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseTime(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatalf("could not load location: %v", err)
+	}
+
+	at := func(value string) time.Time {
+		t.Helper()
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			t.Fatalf("invalid time %q: %v", value, err)
+		}
+		return parsed
+	}
+
+	tests := []struct {
+		name  string
+		input string
+		base  time.Time
+		want  time.Time
+	}{
+		{
+			name:  "tomorrow",
+			input: "tomorrow 20:15",
+			base:  at("2024-03-05T10:00:00+01:00"),
+			want:  at("2024-03-06T20:15:00+01:00"),
+		},
+		{
+			name:  "tomorrow across spring forward",
+			input: "tomorrow 20:15",
+			base:  at("2024-03-30T20:15:00+01:00"),
+			want:  at("2024-03-31T20:15:00+02:00"),
+		},
+		{
+			name:  "tomorrow across fall back",
+			input: "tomorrow 20:15",
+			base:  at("2024-10-26T20:15:00+02:00"),
+			want:  at("2024-10-27T20:15:00+01:00"),
+		},
+		{
+			name:  "clock time across spring forward",
+			input: "20:15",
+			base:  at("2024-03-30T21:00:00+01:00"),
+			want:  at("2024-03-31T20:15:00+02:00"),
+		},
+		{
+			name:  "clock time across fall back",
+			input: "20:15",
+			base:  at("2024-10-26T21:00:00+02:00"),
+			want:  at("2024-10-27T20:15:00+01:00"),
+		},
+		{
+			name:  "clock time after fall back on the same day",
+			input: "04:00",
+			base:  at("2024-10-27T01:00:00+02:00"),
+			want:  at("2024-10-27T04:00:00+01:00"),
+		},
+		{
+			name:  "clock time later today",
+			input: "20:15",
+			base:  at("2024-03-05T10:00:00+01:00"),
+			want:  at("2024-03-05T20:15:00+01:00"),
+		},
+		{
+			name:  "clock time in the past",
+			input: "20:15",
+			base:  at("2024-03-05T22:00:00+01:00"),
+			want:  at("2024-03-06T20:15:00+01:00"),
+		},
+		{
+			name:  "clock time equal to base",
+			input: "20:15:00",
+			base:  at("2024-03-05T20:15:00+01:00"),
+			want:  at("2024-03-06T20:15:00+01:00"),
+		},
+		{
+			name:  "weekday later today",
+			input: "fri 21:00",
+			base:  at("2024-03-01T20:00:00+01:00"),
+			want:  at("2024-03-01T21:00:00+01:00"),
+		},
+		{
+			name:  "weekday after its time of day",
+			input: "fri 21:00",
+			base:  at("2024-03-01T21:30:00+01:00"),
+			want:  at("2024-03-08T21:00:00+01:00"),
+		},
+		{
+			name:  "weekday across spring forward",
+			input: "Monday 21:00",
+			base:  at("2024-03-29T12:00:00+01:00"),
+			want:  at("2024-04-01T21:00:00+02:00"),
+		},
+		{
+			name:  "local timestamp in summer time",
+			input: "2024-07-01 20:15",
+			base:  at("2024-03-05T10:00:00+01:00"),
+			want:  at("2024-07-01T20:15:00+02:00"),
+		},
+		{
+			name:  "rfc3339 with offset ignores the location",
+			input: "2024-03-05T20:15:00-05:00",
+			base:  at("2024-03-05T10:00:00+01:00"),
+			want:  at("2024-03-06T02:15:00+01:00"),
+		},
+		{
+			name:  "duration",
+			input: "90m",
+			base:  at("2024-03-31T01:00:00+01:00"),
+			want:  at("2024-03-31T03:30:00+02:00"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseTime(tt.input, tt.base, berlin)
+			if err != nil {
+				t.Fatalf("parseTime(%q) error = %v", tt.input, err)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("parseTime(%q) = %v, want %v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTime_KeepsWallClock(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatalf("could not load location: %v", err)
+	}
+
+	base := time.Date(2024, 3, 30, 20, 15, 0, 0, berlin)
+	got, err := parseTime("tomorrow 20:15", base, berlin)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the day of the spring forward only has 23 hours
+	if got.Sub(base) != 23*time.Hour {
+		t.Errorf("difference = %v, want 23h", got.Sub(base))
+	}
+	if got.Hour() != 20 || got.Minute() != 15 || got.Location() != berlin {
+		t.Errorf("parseTime() = %v, want 20:15 in %v", got, berlin)
+	}
+}
+
+func TestParseTime_Invalid(t *testing.T) {
+	for _, input := range []string{"", "someday 20:15", "25:00", "tomorrow", "2024-13-01 20:15"} {
+		if _, err := parseTime(input, time.Now(), time.UTC); err == nil {
+			t.Errorf("parseTime(%q) succeeded, want error", input)
+		}
+	}
+}
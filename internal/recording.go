@@ -4,94 +4,547 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
 	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 	"vcr/internal/config"
+	"vcr/internal/dbs"
 	"vcr/internal/runtime"
 
 	"github.com/rs/zerolog/log"
 )
 
+// exitCodeTimeout is the maximum time to wait for the exit code of a container after it has been stopped.
+const exitCodeTimeout = 10 * time.Second
+
+// killTimeout is the maximum time sending a signal to a container may take.
+const killTimeout = 30 * time.Second
+
 type Recorder struct {
 	wg               *sync.WaitGroup
+	db               dbs.Db
 	runtime          runtime.ContainerRuntime
 	programming      config.Programming
 	containerConf    config.ContainerConfig
 	startedRecording atomic.Bool
-}
 
-type VcrOperation func() error
+	// outputName is the name of the output file without its extension, it's set when the recording starts
+	outputName string
+	attempt    int
+	runs       []config.RecordingRun
+
+	// run describes the current attempt, containerId is only set while its container is running
+	run         *config.RecordingRun
+	containerId string
+	// exited receives the exit of the running container
+	exited      <-chan runtime.ExitEvent
+	stopWaiting context.CancelFunc
+
+	// slots limits the amount of simultaneous recordings, nil disables the limit
+	slots *slots
+	// diskGuard checks the free space before a recording is started, nil disables the check
+	diskGuard *DiskGuard
+	// priority mirrors the priority of the programming, other recordings read it while the programming may be updated
+	priority atomic.Int64
+	// startLate keeps retrying to start the recording until the end of the programming, e.g. while the container
+	// runtime is not available
+	startLate bool
+
+	updates    chan config.Programming
+	cancel     chan struct{}
+	cancelOnce sync.Once
+	// cancelMessage describes why the recording has been cancelled, it's set before cancel is closed
+	cancelMessage string
+	// discarded pending recordings are cancelled without changing their state
+	discarded atomic.Bool
+	finished  chan struct{}
+}
 
-func NewRecording(runtime runtime.ContainerRuntime, programming config.Programming,
+func NewRecording(db dbs.Db, runtime runtime.ContainerRuntime, programming config.Programming,
 	containerConf config.ContainerConfig, wg *sync.WaitGroup) (*Recorder, error) {
 
-	return &Recorder{
+	r := &Recorder{
+		db:            db,
 		runtime:       runtime,
 		programming:   programming,
 		containerConf: containerConf,
 		wg:            wg,
-	}, nil
+
+		updates:  make(chan config.Programming, 1),
+		cancel:   make(chan struct{}),
+		finished: make(chan struct{}),
+	}
+	r.priority.Store(int64(programming.Priority))
+	// a recording that has been interrupted continues with the next attempt, so it doesn't overwrite the output
+	r.attempt = r.previousAttempts()
+	return r, nil
+}
+
+// previousAttempts returns the highest attempt that has already been made for the occurrence of the recording, e.g.
+// before vcr has been restarted.
+func (r *Recorder) previousAttempts() int {
+	status := r.programming.Status
+	if status == nil || status.State.IsTerminal() {
+		return 0
+	}
+
+	var attempt int
+	start := r.start()
+	for _, run := range status.Attempts {
+		// attempts of earlier occurrences remain if vcr has not been running at their end
+		if !run.Start.Before(start) && run.Attempt > attempt {
+			attempt = run.Attempt
+		}
+	}
+	return attempt
+}
+
+// transition persists the new state of the recording alongside its programming.
+func (r *Recorder) transition(state config.RecordingState, message string) {
+	err := r.db.Update(r.programming.Name, func(p *config.Programming) error {
+		if p.Status == nil {
+			p.Status = &config.RecordingStatus{}
+		}
+		return p.Status.Transition(state, message, time.Now())
+	})
+	if err != nil && !errors.Is(err, dbs.ErrNotFound) {
+		log.Error().Err(err).Msgf("could not update state of programming '%s' to %s", r.programming.Name, state)
+	}
+}
+
+// IsRecording returns whether the recording has been started.
+func (r *Recorder) IsRecording() bool {
+	return r.startedRecording.Load()
+}
+
+// Cancel aborts a pending recording or stops a running recording.
+func (r *Recorder) Cancel() {
+	r.cancelWithMessage("recording cancelled")
+}
+
+// Discard aborts a pending recording without marking it as cancelled, e.g. because its programming has been
+// rescheduled and a new recording is created when it's due. A running recording is stopped like with Cancel.
+func (r *Recorder) Discard() {
+	r.discarded.Store(true)
+	r.cancelWithMessage("recording rescheduled")
+}
+
+// Preempt stops the recording in favour of the recording of a programming with a higher priority.
+func (r *Recorder) Preempt(by string) {
+	r.cancelWithMessage(fmt.Sprintf("preempted by programming '%s'", by))
+}
+
+// isCancelled returns whether the recording has been cancelled.
+func (r *Recorder) isCancelled() bool {
+	select {
+	case <-r.cancel:
+		return true
+	default:
+		return false
+	}
+}
+
+func (r *Recorder) cancelWithMessage(message string) {
+	r.cancelOnce.Do(func() {
+		r.cancelMessage = message
+		close(r.cancel)
+	})
+}
+
+// Reschedule updates the programming of the recording, e.g. its times, url or retry policy. The start is ignored if the
+// recording is already running. It never blocks, an update that has not been picked up yet is replaced by the newer
+// one.
+func (r *Recorder) Reschedule(programming config.Programming) {
+	for {
+		select {
+		case r.updates <- programming:
+			return
+		case <-r.finished:
+			return
+		default:
+		}
+
+		select {
+		case <-r.updates:
+		default:
+		}
+	}
+}
+
+// Adopt makes the recorder take over a container that is already running, e.g. a container that has been left running
+// by a previous vcr process. Must be called before Schedule.
+func (r *Recorder) Adopt(info runtime.ContainerInfo) {
+	r.attempt++
+
+	r.outputName = buildOutputName(r.programming.Date, r.programming.Name, r.attempt)
+	r.run = &config.RecordingRun{
+		Name:        r.programming.Name,
+		Attempt:     r.attempt,
+		ContainerId: info.Id,
+		Start:       info.Created,
+	}
+	r.containerId = info.Id
+	if r.slots != nil {
+		r.slots.hold(r)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	r.stopWaiting = cancel
+	r.exited = r.runtime.Wait(ctx, info.Id)
+
+	r.startedRecording.Store(true)
+	if r.programming.Status == nil || r.programming.Status.State != config.StateRecording {
+		r.transition(config.StateRecording, fmt.Sprintf("adopted container %s, attempt %d", info.Id, r.attempt))
+	}
+	log.Info().Str("id", info.Id).Msgf("Adopted container of recording %s", r.programming.Name)
+}
+
+// start returns the time the recording starts, including the padding before the programming.
+func (r *Recorder) start() time.Time {
+	return r.programming.PaddedOccurrence().Start
+}
+
+// end returns the time the recording ends, including the padding after the programming. Returns nil if the
+// programming has no end.
+func (r *Recorder) end() *time.Time {
+	end := r.programming.PaddedOccurrence().Until
+	if end == nil || end.IsZero() {
+		return nil
+	}
+	return end
+}
+
+// Runs returns all attempts of the recording. Must not be called before Schedule returned.
+func (r *Recorder) Runs() []config.RecordingRun {
+	return r.runs
 }
 
 func (r *Recorder) GetYtpArgs() []string {
-	now := time.Now()
-	datePrefix := now.Format("20060102-1504")
+	outputName := r.outputName
+	if len(outputName) == 0 {
+		outputName = buildOutputName(time.Now(), r.programming.Name, 1)
+	}
 	dirPrefix := ""
 	if r.containerConf.Mount != nil {
 		dirPrefix = r.containerConf.Mount.ContainerPath
 	}
 	return []string{
 		"-o",
-		fmt.Sprintf("%s/%s-%s.%%(ext)s", dirPrefix, datePrefix, strings.ToLower(r.programming.Name)),
+		fmt.Sprintf("%s/%s.%%(ext)s", dirPrefix, outputName),
 		r.programming.Url,
 	}
 }
 
+// buildOutputName returns the name of the output file without extension. Restarted attempts write to numbered parts.
+func buildOutputName(date time.Time, name string, attempt int) string {
+	if attempt > 1 {
+		return fmt.Sprintf("%s-%s-part%d", date.Format("20060102-1504"), strings.ToLower(name), attempt)
+	}
+	return fmt.Sprintf("%s-%s", date.Format("20060102-1504"), strings.ToLower(name))
+}
+
+// findOutput returns the largest file written by the recording and its size.
+func (r *Recorder) findOutput() (string, int64) {
+	if r.containerConf.Mount == nil || len(r.containerConf.Mount.HostPath) == 0 || len(r.outputName) == 0 {
+		return "", 0
+	}
+
+	matches, err := filepath.Glob(filepath.Join(r.containerConf.Mount.HostPath, r.outputName+".*"))
+	if err != nil {
+		return "", 0
+	}
+
+	var file string
+	var size int64
+	for _, match := range matches {
+		info, err := os.Stat(match)
+		if err != nil || info.IsDir() {
+			continue
+		}
+		if info.Size() >= size {
+			file, size = match, info.Size()
+		}
+	}
+	return file, size
+}
+
+// finishRun completes the information about the current attempt after it ended and persists it in the status of the
+// programming.
+func (r *Recorder) finishRun(err error) {
+	if r.stopWaiting != nil {
+		r.stopWaiting()
+		r.stopWaiting = nil
+	}
+	r.exited = nil
+	r.containerId = ""
+	if r.slots != nil {
+		r.slots.release(r)
+	}
+
+	if r.run == nil {
+		return
+	}
+
+	now := time.Now()
+	r.run.Stop = &now
+	if err != nil {
+		r.run.Error = err.Error()
+	}
+	r.run.OutputFile, r.run.OutputSize = r.findOutput()
+
+	run := *r.run
+	r.runs = append(r.runs, run)
+	r.run = nil
+
+	dbErr := r.db.Update(r.programming.Name, func(p *config.Programming) error {
+		if p.Status == nil {
+			p.Status = &config.RecordingStatus{}
+		}
+		p.Status.Attempts = append(p.Status.Attempts, run)
+		return nil
+	})
+	if dbErr != nil && !errors.Is(dbErr, dbs.ErrNotFound) {
+		log.Error().Err(dbErr).Msgf("could not persist attempt of programming '%s'", r.programming.Name)
+	}
+}
+
+// retryAfter returns the backoff before the next attempt, if another attempt is allowed by the retry policy.
+func (r *Recorder) retryAfter() (time.Duration, bool) {
+	policy := r.programming.Retry
+	if policy == nil || r.attempt >= policy.MaxAttempts {
+		return 0, false
+	}
+
+	backoff := policy.Backoff(r.attempt)
+	if end := r.end(); end != nil && !time.Now().Add(backoff).Before(*end) {
+		return 0, false
+	}
+	return backoff, true
+}
+
+// fail finishes the current attempt and fails the recording, unless the retry policy allows another attempt. Returns
+// the backoff before the next attempt if the recording is retried.
+func (r *Recorder) fail(err error) (time.Duration, bool) {
+	r.finishRun(err)
+
+	backoff, retry := r.retryAfter()
+	if !retry {
+		log.Error().Err(err).Msgf("Recording %s failed", r.programming.Name)
+		r.transition(config.StateFailed, err.Error())
+		return 0, false
+	}
+
+	log.Warn().Err(err).Msgf("Attempt %d of recording %s failed, retrying in %v", r.attempt, r.programming.Name, backoff)
+	r.transition(config.StateRetrying, fmt.Sprintf("attempt %d failed: %v, retrying in %v", r.attempt, err, backoff))
+	return backoff, true
+}
+
+// failStart finishes an attempt that could not be started. If the recording is started late, it's retried until the
+// end of the programming, even if the retry policy does not allow another attempt.
+func (r *Recorder) failStart(err error) (time.Duration, bool) {
+	if _, retry := r.retryAfter(); retry || !r.startLate {
+		return r.fail(err)
+	}
+
+	policy := r.programming.Retry
+	if policy == nil {
+		policy = &config.RetryPolicy{
+			InitialBackoff: config.DefaultRetryInitialBackoff,
+			MaxBackoff:     config.DefaultRetryMaxBackoff,
+		}
+	}
+	backoff := policy.Backoff(r.attempt)
+	if end := r.end(); end != nil && !time.Now().Add(backoff).Before(*end) {
+		return r.fail(err)
+	}
+
+	r.finishRun(err)
+	log.Warn().Err(err).Msgf("Could not start recording %s, retrying in %v", r.programming.Name, backoff)
+	r.transition(config.StateRetrying, fmt.Sprintf("could not start attempt %d: %v, retrying in %v", r.attempt, err, backoff))
+	return backoff, true
+}
+
+// update takes over the changes of the programming. The start is only changed if the recording has not been started
+// yet, the name and status are kept.
+func (r *Recorder) update(programming config.Programming) {
+	r.programming.Url = programming.Url
+	r.programming.Until = programming.Until
+	r.programming.Retry = programming.Retry
+	r.programming.Padding = programming.Padding
+	r.programming.Priority = programming.Priority
+	r.priority.Store(int64(programming.Priority))
+	if !r.startedRecording.Load() {
+		r.programming.Date = programming.Date
+		r.programming.Recurrence = programming.Recurrence
+	}
+}
+
+// Priority returns the priority of the programming. Unlike the programming itself, it's safe to read while the
+// recording is running.
+func (r *Recorder) Priority() int {
+	return int(r.priority.Load())
+}
+
 func (r *Recorder) Schedule(done chan bool) error {
 	r.wg.Add(1)
-	defer r.wg.Done()
+	defer func() {
+		// containers left running on shutdown still hold a slot, a recording waiting for it must not block shutdown
+		if r.slots != nil {
+			r.slots.release(r)
+		}
+		close(r.finished)
+		r.wg.Done()
+	}()
+
+	adopted := r.startedRecording.Load()
+	if !adopted {
+		// programmings that have already started are recorded late, as long as they have not ended yet
+		if end := r.end(); end != nil && !end.After(time.Now()) {
+			err := errors.New("end of programming is in the past")
+			r.transition(config.StateFailed, err.Error())
+			return err
+		}
 
-	if !r.programming.IsUpcoming() {
-		return errors.New("record date is in the past")
+		log.Info().Msgf("Scheduling recording for %v", r.start())
+		if r.programming.IsUpcoming() {
+			r.transition(config.StateScheduled, fmt.Sprintf("scheduled for %s", r.programming.Date.Format(time.RFC3339)))
+		} else {
+			r.transition(config.StateScheduled, fmt.Sprintf("starting late, programming started at %s", r.programming.Date.Format(time.RFC3339)))
+		}
 	}
 
-	ctx, cancel := context.WithCancel(context.Background())
-	errChan := make(chan error)
-	go func() {
-		log.Info().Msgf("Scheduling recording for %v", r.programming.Date)
-		if err := r.scheduleRun(ctx, r.programming.Date, r.record); err != nil {
-			errChan <- err
+	// the stop timer is only armed after the recording has been started
+	var stopTimer *time.Timer
+	var stopChan <-chan time.Time
+	armStop := func() {
+		if stopTimer != nil {
+			stopTimer.Stop()
+			stopTimer, stopChan = nil, nil
+		}
+		if end := r.end(); end != nil {
+			log.Info().Msgf("Scheduling stop for %v", *end)
+			stopTimer = time.NewTimer(time.Until(*end))
+			stopChan = stopTimer.C
+		}
+	}
+	defer func() {
+		if stopTimer != nil {
+			stopTimer.Stop()
+		}
+		if r.stopWaiting != nil {
+			r.stopWaiting()
 		}
 	}()
 
-	if r.programming.Until != nil && !r.programming.Until.IsZero() {
-		go func() {
-			log.Info().Msgf("Scheduling stop for %v", r.programming.Until)
-			if err := r.scheduleRun(ctx, *r.programming.Until, r.stop); err != nil {
-				errChan <- err
+	startTimer := time.NewTimer(time.Until(r.start()))
+	defer startTimer.Stop()
+	if adopted {
+		// the container is already running, only its stop needs to be scheduled
+		if !startTimer.Stop() {
+			<-startTimer.C
+		}
+		armStop()
+	}
+
+	for {
+		select {
+		case <-startTimer.C:
+			if err := r.record(done); err != nil {
+				if errors.Is(err, errSlotAborted) {
+					// cancel or done is handled by the next iteration
+					continue
+				}
+				backoff, retry := r.failStart(err)
+				if !retry {
+					return err
+				}
+				startTimer.Reset(backoff)
+				continue
+			}
+			if stopTimer == nil {
+				armStop()
+			}
+		case <-stopChan:
+			if len(r.containerId) == 0 {
+				err := errors.New("end of programming reached while waiting to retry recording")
+				r.transition(config.StateFailed, err.Error())
+				return err
+			}
+			return r.stopWithState(config.StateCompleted, "end of programming reached")
+		case event := <-r.exited:
+			if event.Err != nil {
+				// the container is still stopped by the stop timer
+				log.Error().Err(event.Err).Msgf("could not wait for container of recording %s", r.programming.Name)
+				r.exited = nil
+				continue
+			}
+			err := r.handleExit(event)
+			if err == nil {
+				return nil
 			}
-		}()
+			backoff, retry := r.fail(err)
+			if !retry {
+				return err
+			}
+			startTimer.Reset(backoff)
+		case programming := <-r.updates:
+			r.update(programming)
+			if r.startedRecording.Load() {
+				armStop()
+				continue
+			}
+			log.Info().Msgf("Rescheduling recording for %v", r.start())
+			r.transition(config.StateScheduled, fmt.Sprintf("rescheduled for %s", r.programming.Date.Format(time.RFC3339)))
+			startTimer.Stop()
+			startTimer.Reset(time.Until(r.start()))
+		case <-r.cancel:
+			log.Warn().Msgf("Recording of %s cancelled: %s", r.programming.Name, r.cancelMessage)
+			return r.stopIfRecording(config.StateCancelled, r.cancelMessage, !r.discarded.Load())
+		case <-done:
+			log.Warn().Msgf("recording: received done")
+			if r.containerConf.KeepRunningOnShutdown && len(r.containerId) > 0 {
+				log.Info().Msgf("Leaving container %s of recording %s running", r.containerId, r.programming.Name)
+				return nil
+			}
+			// the recording is resumed by the catch up after a restart, so it's not marked as cancelled
+			return r.stopIfRecording(config.StateInterrupted, "vcr shutting down", false)
+		}
 	}
+}
 
-	select {
-	case <-done:
-		log.Warn().Msgf("recording: received done")
-		cancel()
-	case err := <-errChan:
-		if err != nil {
-			log.Error().Err(err).Msgf("Error on VcrOperation")
-			cancel()
+// record starts an attempt of the recording. done aborts waiting for a slot of a preempted recording.
+func (r *Recorder) record(done <-chan bool) error {
+	now := time.Now()
+	r.attempt++
+	log.Info().Msgf("Starting recording, attempt %d", r.attempt)
+
+	// the name refers to the start of the programming, regardless of padding and retries
+	r.outputName = buildOutputName(r.programming.Date, r.programming.Name, r.attempt)
+	r.run = &config.RecordingRun{
+		Name:    r.programming.Name,
+		Attempt: r.attempt,
+		Start:   now,
+	}
+
+	if r.diskGuard != nil {
+		if err := r.diskGuard.check(); err != nil {
 			return err
 		}
 	}
 
-	return nil
-}
-
-func (r *Recorder) record() error {
-	log.Info().Msg("Starting recording")
+	if r.slots != nil {
+		if err := r.slots.acquire(r, done); err != nil {
+			if errors.Is(err, errSlotAborted) {
+				// the attempt has not been made
+				r.attempt--
+				r.run = nil
+			}
+			return err
+		}
+	}
 
 	r.containerConf.Args = r.GetYtpArgs()
 	log.Info().Msgf("Starting recording, creating container using image %s with args %v", r.containerConf.Image, r.containerConf.Args)
@@ -99,41 +552,147 @@ func (r *Recorder) record() error {
 	if err != nil {
 		return err
 	}
+	r.run.ContainerId = id
+	r.containerId = id
+
+	ctx, cancel := context.WithCancel(context.Background())
+	r.stopWaiting = cancel
+	r.exited = r.runtime.Wait(ctx, id)
 
 	r.startedRecording.Store(true)
+	r.transition(config.StateRecording, fmt.Sprintf("started container %s, attempt %d", id, r.attempt))
 	log.Info().Str("id", id).Msg("Started container")
 	return nil
 }
 
+// stop sends the configured stop signal to the container and waits for it to exit. If the container does not exit
+// within the grace period, it's killed.
 func (r *Recorder) stop() error {
 	log.Info().Msgf("Stopping recording %s", r.programming.Name)
-	id, err := r.runtime.FindByName(r.programming.Name)
-	if err != nil {
+	id := r.containerId
+	if len(id) == 0 {
+		var err error
+		id, err = r.runtime.FindByName(r.programming.Name)
+		if err != nil {
+			return err
+		}
+		log.Info().Msgf("Found container %s", id)
+	}
+
+	exited := r.exited
+	if exited == nil {
+		waitCtx, cancelWait := context.WithCancel(context.Background())
+		defer cancelWait()
+		exited = r.runtime.Wait(waitCtx, id)
+	}
+	// the exit is consumed here
+	r.exited = nil
+
+	signal := r.containerConf.StopSignal
+	if len(signal) == 0 {
+		signal = runtime.SignalKill
+	}
+	if err := r.kill(id, signal); err != nil {
 		return err
 	}
-	log.Info().Msgf("Found container %s", id)
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+	if signal != runtime.SignalKill {
+		if r.awaitExit(exited, r.containerConf.StopGracePeriod) {
+			return nil
+		}
+		log.Warn().Msgf("Container %s did not exit within %v, killing it", id, r.containerConf.StopGracePeriod)
+		if err := r.kill(id, runtime.SignalKill); err != nil {
+			// the container may have exited in the meantime
+			if r.awaitExit(exited, time.Second) {
+				return nil
+			}
+			return err
+		}
+	}
+
+	r.awaitExit(exited, exitCodeTimeout)
+	return nil
+}
+
+// kill sends the signal to the container. Each signal gets its own timeout, so the grace period between the stop
+// signal and SIGKILL does not count against it.
+func (r *Recorder) kill(id string, signal string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), killTimeout)
 	defer cancel()
-	return r.runtime.KillContainer(ctx, id)
+	return r.runtime.KillContainer(ctx, id, signal)
 }
 
-func (r *Recorder) scheduleRun(ctx context.Context, until time.Time, run VcrOperation) error {
-	timer := time.NewTimer(time.Until(until))
-	r.wg.Add(1)
-	defer func() {
-		timer.Stop()
-		r.wg.Done()
-	}()
+// handleExit is called when the container exits on its own. Exiting before the end of the programming or with a
+// non-zero exit code is returned as error, the caller decides whether to retry.
+func (r *Recorder) handleExit(event runtime.ExitEvent) error {
+	r.setExitCode(event.ExitCode)
+
+	if end := r.end(); end != nil && time.Now().Before(*end) {
+		return fmt.Errorf("container exited prematurely with code %d", event.ExitCode)
+	}
+	if event.ExitCode != 0 {
+		return fmt.Errorf("container exited with code %d", event.ExitCode)
+	}
+
+	r.finishRun(nil)
+	log.Info().Msgf("Container of recording %s exited", r.programming.Name)
+	r.transition(config.StateCompleted, "container exited")
+	return nil
+}
+
+func (r *Recorder) setExitCode(exitCode int64) {
+	if r.run != nil {
+		r.run.ExitCode = &exitCode
+	}
+}
+
+// awaitExit waits for the container to exit and records its exit code. Returns false if the container did not exit
+// within the timeout.
+func (r *Recorder) awaitExit(exited <-chan runtime.ExitEvent, timeout time.Duration) bool {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
 
 	select {
-	case <-timer.C:
-		return run()
-	case <-ctx.Done():
-		if r.startedRecording.Load() {
-			return run()
+	case event := <-exited:
+		if event.Err != nil {
+			log.Error().Err(event.Err).Msgf("could not wait for container of recording %s", r.programming.Name)
+			return false
 		}
+		r.setExitCode(event.ExitCode)
+		return true
+	case <-timer.C:
+		return false
+	}
+}
+
+// stopWithState stops the running recording and transitions to the given state afterwards.
+func (r *Recorder) stopWithState(state config.RecordingState, message string) error {
+	r.transition(config.StateStopping, message)
+	err := r.stop()
+	r.finishRun(err)
+	if err != nil {
+		r.transition(config.StateFailed, fmt.Sprintf("could not stop recording: %v", err))
+		return err
+	}
+	r.transition(state, message)
+	return nil
+}
+
+// stopIfRecording stops a running recording and moves it to the given state, pending recordings are only moved if
+// markPending is set.
+func (r *Recorder) stopIfRecording(state config.RecordingState, message string, markPending bool) error {
+	if !r.startedRecording.Load() {
 		log.Warn().Msg("Scheduled run cancelled")
+		if markPending {
+			r.transition(state, message)
+		}
+		return nil
+	}
+
+	if len(r.containerId) == 0 {
+		// waiting to retry, there is no container to stop
+		r.transition(state, message)
 		return nil
 	}
+	return r.stopWithState(state, message)
 }
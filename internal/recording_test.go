@@ -0,0 +1,79 @@
+package internal
+
+import (
+	"sync"
+	"testing"
+	"time"
+	"vcr/internal/config"
+	"vcr/internal/dbs"
+	"vcr/internal/runtime"
+)
+
+func TestRecorder_StopKillsAfterGracePeriod(t *testing.T) {
+	fake := newFakeRuntime()
+	fake.ignoreStopSignal = true
+
+	gracePeriod := 500 * time.Millisecond
+	conf := config.ContainerConfig{Image: "image", StopSignal: "SIGINT", StopGracePeriod: gracePeriod}
+	r, err := NewRecording(dbs.NewMemoryDb(), fake, config.Programming{Name: "news"}, conf, &sync.WaitGroup{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := r.record(nil); err != nil {
+		t.Fatalf("record() error = %v", err)
+	}
+	if err := r.stop(); err != nil {
+		t.Fatalf("stop() error = %v", err)
+	}
+
+	kills := fake.killCalls()
+	if len(kills) != 2 || kills[0].signal != "SIGINT" || kills[1].signal != runtime.SignalKill {
+		t.Fatalf("kill calls = %+v, want SIGINT followed by SIGKILL", kills)
+	}
+	// the grace period must not be deducted from the timeout of SIGKILL
+	if kills[1].remaining < killTimeout-gracePeriod/2 {
+		t.Errorf("SIGKILL sent with %v left, want a fresh timeout of %v", kills[1].remaining, killTimeout)
+	}
+	if running := fake.running(); len(running) != 0 {
+		t.Errorf("running containers = %v, want none", running)
+	}
+}
+
+func TestRecorder_ContinuesAttemptsOfInterruptedRecording(t *testing.T) {
+	start := time.Date(2024, 3, 1, 20, 0, 0, 0, time.UTC)
+	status := func(state config.RecordingState, attempts ...config.RecordingRun) *config.RecordingStatus {
+		return &config.RecordingStatus{State: state, Attempts: attempts}
+	}
+	run := func(attempt int, at time.Time) config.RecordingRun {
+		return config.RecordingRun{Name: "news", Attempt: attempt, Start: at}
+	}
+
+	tests := []struct {
+		name        string
+		status      *config.RecordingStatus
+		wantAttempt int
+		wantOutput  string
+	}{
+		{name: "new", wantAttempt: 1, wantOutput: "20240301-2000-news"},
+		{name: "interrupted", status: status(config.StateInterrupted, run(1, start), run(2, start.Add(time.Minute))), wantAttempt: 3, wantOutput: "20240301-2000-news-part3"},
+		{name: "crashed while recording", status: status(config.StateRecording, run(1, start)), wantAttempt: 2, wantOutput: "20240301-2000-news-part2"},
+		{name: "completed", status: status(config.StateCompleted, run(1, start)), wantAttempt: 1, wantOutput: "20240301-2000-news"},
+		{name: "earlier occurrence", status: status(config.StateInterrupted, run(1, start.Add(-24*time.Hour))), wantAttempt: 1, wantOutput: "20240301-2000-news"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			until := start.Add(time.Hour)
+			p := config.Programming{Name: "news", Date: start, Until: &until, Status: tt.status}
+			r, err := NewRecording(dbs.NewMemoryDb(), newFakeRuntime(), p, config.ContainerConfig{}, &sync.WaitGroup{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			r.Adopt(runtime.ContainerInfo{Id: "abc", Name: "news", Running: true, Created: start})
+			if r.attempt != tt.wantAttempt || r.outputName != tt.wantOutput {
+				t.Errorf("attempt = %d, output = %s, want %d, %s", r.attempt, r.outputName, tt.wantAttempt, tt.wantOutput)
+			}
+		})
+	}
+}
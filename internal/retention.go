@@ -0,0 +1,251 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+	"vcr/internal/config"
+
+	"github.com/rs/zerolog/log"
+	"go.uber.org/multierr"
+)
+
+// inUseGracePeriod protects files that have been modified recently, they most likely belong to a running recording.
+const inUseGracePeriod = time.Minute
+
+// recordingFilePattern matches the names of files written by recordings, see buildOutputName. Besides the extension,
+// the suffixes of intermediate files of yt-dlp are not part of the name, e.g. the format of "news.f137.mp4" or the
+// download state of "news.mp4.part".
+var recordingFilePattern = regexp.MustCompile(`^(\d{8}-\d{4})-(.+?)(?:-part\d+)?(?:\.f\d+)?(?:\.temp)?\.[^.]+(?:\.(?:part|ytdl)(?:-Frag\d+)?)?$`)
+
+// RetentionCandidate is a recorded file that is removed by the retention policy.
+type RetentionCandidate struct {
+	Path    string    `json:"path"`
+	Name    string    `json:"name"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+	Reason  string    `json:"reason"`
+}
+
+type recordingFile struct {
+	path    string
+	name    string
+	start   string
+	size    int64
+	modTime time.Time
+}
+
+// Retention removes recorded files from the host path of the mount by age, total size and amount of recordings per
+// programming.
+type Retention struct {
+	path string
+	conf config.RetentionConfig
+}
+
+// NewRetention returns the retention for the given path. The retention is disabled if the path is empty or no rule
+// is configured.
+func NewRetention(path string, conf config.RetentionConfig) (*Retention, error) {
+	if conf.Interval <= 0 {
+		return nil, errors.New("retention interval must be positive")
+	}
+
+	return &Retention{
+		path: path,
+		conf: conf,
+	}, nil
+}
+
+func (r *Retention) enabled() bool {
+	return len(r.path) > 0 && (r.conf.MaxAge > 0 || r.conf.MaxTotalBytes > 0 || r.conf.KeepLast > 0)
+}
+
+// Run periodically applies the retention until the context is cancelled.
+func (r *Retention) Run(ctx context.Context, wg *sync.WaitGroup) {
+	if !r.enabled() {
+		return
+	}
+
+	wg.Add(1)
+	defer wg.Done()
+
+	ticker := time.NewTicker(r.conf.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := r.Apply(); err != nil {
+			log.Error().Err(err).Msg("could not apply retention")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Apply removes all files selected by the retention. In dry run mode, the files are only logged.
+func (r *Retention) Apply() error {
+	candidates, err := r.Plan()
+	if err != nil {
+		return err
+	}
+
+	var errs error
+	for _, candidate := range candidates {
+		if r.conf.DryRun {
+			log.Info().Msgf("Retention would remove %s: %s", candidate.Path, candidate.Reason)
+			continue
+		}
+
+		log.Info().Msgf("Retention removes %s: %s", candidate.Path, candidate.Reason)
+		if err := os.Remove(candidate.Path); err != nil {
+			errs = multierr.Append(errs, err)
+		}
+	}
+	return errs
+}
+
+// Plan returns the files that are removed by the retention, ordered by their path.
+func (r *Retention) Plan() ([]RetentionCandidate, error) {
+	if !r.enabled() {
+		return nil, nil
+	}
+
+	files, err := r.scan()
+	if err != nil {
+		return nil, err
+	}
+
+	return r.selectForRemoval(files, time.Now()), nil
+}
+
+func (r *Retention) scan() ([]recordingFile, error) {
+	entries, err := os.ReadDir(r.path)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []recordingFile
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+
+		match := recordingFilePattern.FindStringSubmatch(entry.Name())
+		if match == nil {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			// the file has been removed in the meantime
+			continue
+		}
+
+		files = append(files, recordingFile{
+			path:    filepath.Join(r.path, entry.Name()),
+			name:    match[2],
+			start:   match[1],
+			size:    info.Size(),
+			modTime: info.ModTime(),
+		})
+	}
+	return files, nil
+}
+
+func (r *Retention) selectForRemoval(files []recordingFile, now time.Time) []RetentionCandidate {
+	reasons := map[string]string{}
+
+	if r.conf.MaxAge > 0 {
+		for _, file := range files {
+			if now.Sub(file.modTime) > r.conf.MaxAge {
+				reasons[file.path] = fmt.Sprintf("older than %v", r.conf.MaxAge)
+			}
+		}
+	}
+
+	if r.conf.KeepLast > 0 {
+		// all parts of a recording share the name and start
+		starts := map[string][]string{}
+		seen := map[string]bool{}
+		for _, file := range files {
+			key := file.name + "/" + file.start
+			if !seen[key] {
+				seen[key] = true
+				starts[file.name] = append(starts[file.name], file.start)
+			}
+		}
+
+		for name, recordings := range starts {
+			if len(recordings) <= r.conf.KeepLast {
+				continue
+			}
+			// the format of the start sorts chronologically
+			sort.Sort(sort.Reverse(sort.StringSlice(recordings)))
+			expired := map[string]bool{}
+			for _, start := range recordings[r.conf.KeepLast:] {
+				expired[start] = true
+			}
+			for _, file := range files {
+				if file.name == name && expired[file.start] {
+					if _, ok := reasons[file.path]; !ok {
+						reasons[file.path] = fmt.Sprintf("more than %d recordings of '%s'", r.conf.KeepLast, name)
+					}
+				}
+			}
+		}
+	}
+
+	if r.conf.MaxTotalBytes > 0 {
+		var total uint64
+		var remaining []recordingFile
+		for _, file := range files {
+			if _, ok := reasons[file.path]; ok {
+				continue
+			}
+			total += uint64(file.size)
+			remaining = append(remaining, file)
+		}
+
+		sort.Slice(remaining, func(i, j int) bool {
+			return remaining[i].modTime.Before(remaining[j].modTime)
+		})
+		for _, file := range remaining {
+			if total <= r.conf.MaxTotalBytes {
+				break
+			}
+			if now.Sub(file.modTime) < inUseGracePeriod {
+				continue
+			}
+			reasons[file.path] = fmt.Sprintf("total size exceeds %d bytes", r.conf.MaxTotalBytes)
+			total -= uint64(file.size)
+		}
+	}
+
+	var candidates []RetentionCandidate
+	for _, file := range files {
+		reason, ok := reasons[file.path]
+		if !ok || now.Sub(file.modTime) < inUseGracePeriod {
+			continue
+		}
+		candidates = append(candidates, RetentionCandidate{
+			Path:    file.path,
+			Name:    file.name,
+			Size:    file.size,
+			ModTime: file.modTime,
+			Reason:  reason,
+		})
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].Path < candidates[j].Path
+	})
+	return candidates
+}
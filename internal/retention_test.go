@@ -0,0 +1,152 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+	"vcr/internal/config"
+)
+
+func TestRetention_Scan(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"20240301-2000-news.mp4":             "news",
+		"20240301-2000-news-part2.mp4":       "news",
+		"20240302-2000-news.f137.mp4":        "news",
+		"20240302-2000-news.f140.m4a":        "news",
+		"20240303-2000-news.mp4.part":        "news",
+		"20240303-2000-news.f137.mp4.part":   "news",
+		"20240303-2000-news.mp4.ytdl":        "news",
+		"20240304-2000-news-part3.temp.mp4":  "news",
+		"20240304-2000-news.mp4.part-Frag12": "news",
+		"20240301-2100-mr. robot.mkv":        "mr. robot",
+		"20240301-2100-late-night-show.mkv":  "late-night-show",
+	}
+	for name := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("recording"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, name := range []string{"notes.txt", "2024-news.mp4", "20240301-2000-news"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(dir, "20240301-2000-news.d"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := NewRetention(dir, config.RetentionConfig{KeepLast: 1, Interval: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	scanned, err := r.scan()
+	if err != nil {
+		t.Fatalf("scan() error = %v", err)
+	}
+
+	if len(scanned) != len(files) {
+		t.Errorf("scan() found %d files, want %d: %+v", len(scanned), len(files), scanned)
+	}
+	for _, file := range scanned {
+		base := filepath.Base(file.path)
+		want, ok := files[base]
+		if !ok {
+			t.Errorf("scan() found unexpected file %s", base)
+			continue
+		}
+		if file.name != want || file.start != base[:13] || file.size != int64(len("recording")) {
+			t.Errorf("scan() %s = name %q, start %q, size %d, want name %q", base, file.name, file.start, file.size, want)
+		}
+	}
+}
+
+func TestRetention_SelectForRemoval(t *testing.T) {
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+	file := func(name, start string, size int64, age time.Duration) recordingFile {
+		path := start + "-" + name + ".mp4"
+		return recordingFile{path: path, name: name, start: start, size: size, modTime: now.Add(-age)}
+	}
+	part := func(f recordingFile, suffix string) recordingFile {
+		f.path = f.path[:len(f.path)-len(".mp4")] + suffix
+		return f
+	}
+	day := 24 * time.Hour
+
+	tests := []struct {
+		name  string
+		conf  config.RetentionConfig
+		files []recordingFile
+		want  []string
+	}{
+		{
+			name:  "max age",
+			conf:  config.RetentionConfig{MaxAge: 7 * day},
+			files: []recordingFile{file("news", "20240301-2000", 1, 8*day), file("news", "20240308-2000", 1, day)},
+			want:  []string{"20240301-2000-news.mp4"},
+		},
+		{
+			name: "keep last counts recordings, not files",
+			conf: config.RetentionConfig{KeepLast: 2},
+			files: []recordingFile{
+				file("news", "20240301-2000", 1, 9*day),
+				part(file("news", "20240301-2000", 1, 9*day), "-part2.mp4"),
+				file("news", "20240302-2000", 1, 8*day),
+				part(file("news", "20240302-2000", 1, 8*day), ".f140.m4a"),
+				file("news", "20240303-2000", 1, 7*day),
+				part(file("news", "20240303-2000", 1, 7*day), ".mp4.part"),
+				file("sports", "20240301-1800", 1, 9*day),
+			},
+			want: []string{"20240301-2000-news-part2.mp4", "20240301-2000-news.mp4"},
+		},
+		{
+			name: "max total bytes removes oldest",
+			conf: config.RetentionConfig{MaxTotalBytes: 250},
+			files: []recordingFile{
+				file("news", "20240303-2000", 100, day),
+				file("news", "20240301-2000", 100, 3*day),
+				file("sports", "20240302-1800", 100, 2*day),
+			},
+			want: []string{"20240301-2000-news.mp4"},
+		},
+		{
+			name: "max total bytes ignores files removed by other rules",
+			conf: config.RetentionConfig{MaxTotalBytes: 150, KeepLast: 1},
+			files: []recordingFile{
+				file("news", "20240301-2000", 100, 3*day),
+				file("news", "20240303-2000", 100, day),
+				file("sports", "20240302-1800", 100, 2*day),
+			},
+			want: []string{"20240301-2000-news.mp4", "20240302-1800-sports.mp4"},
+		},
+		{
+			name: "files in use are kept",
+			conf: config.RetentionConfig{MaxTotalBytes: 50, KeepLast: 1},
+			files: []recordingFile{
+				file("news", "20240301-2000", 100, 10*time.Second),
+				file("news", "20240302-2000", 100, 20*time.Second),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &Retention{path: "/recordings", conf: tt.conf}
+
+			var got []string
+			for _, candidate := range r.selectForRemoval(tt.files, now) {
+				got = append(got, candidate.Path)
+			}
+			sort.Strings(tt.want)
+			if len(got) != len(tt.want) {
+				t.Fatalf("selectForRemoval() = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("selectForRemoval() = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
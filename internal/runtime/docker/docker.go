@@ -3,6 +3,7 @@ package docker
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"time"
@@ -17,14 +18,33 @@ import (
 	"github.com/rs/zerolog/log"
 )
 
-const VcrLabelNameKey = "vcr_name"
-
 type Docker struct {
 	client *client.Client
 }
 
-func NewDockerClient() (*Docker, error) {
-	cli, err := client.NewClientWithOpts(client.WithHostFromEnv(), client.WithVersion("1.41"))
+// NewDockerClient connects to the configured daemon. The DOCKER_* environment variables are used for values that
+// are not configured.
+func NewDockerClient(conf config.DockerConfig) (*Docker, error) {
+	opts := []client.Opt{
+		client.FromEnv,
+		client.WithAPIVersionNegotiation(),
+	}
+
+	if len(conf.DockerHost) > 0 {
+		opts = append(opts, client.WithHost(conf.DockerHost))
+	}
+
+	// a pinned version disables the negotiation
+	if len(conf.ApiVersion) > 0 {
+		opts = append(opts, client.WithVersion(conf.ApiVersion))
+	}
+
+	// overrides the tls config of DOCKER_CERT_PATH, the client switches to https
+	if conf.UsesTls() {
+		opts = append(opts, client.WithTLSClientConfig(conf.TlsCaCert, conf.TlsCert, conf.TlsKey))
+	}
+
+	cli, err := client.NewClientWithOpts(opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -88,8 +108,8 @@ func (d *Docker) Run(ctx context.Context, name string, conf config.ContainerConf
 		Volumes: translateVolumes(conf),
 		Cmd:     conf.Args,
 		Labels: map[string]string{
-			VcrLabelNameKey: name,
-			"app":           "vcr",
+			runtime.VcrLabelNameKey: name,
+			runtime.VcrLabelAppKey:  runtime.VcrLabelAppValue,
 		},
 	}
 
@@ -117,7 +137,7 @@ func (d *Docker) FindByName(name string) (string, error) {
 	filter := []filters.KeyValuePair{
 		{
 			Key:   "label",
-			Value: fmt.Sprintf("%s=%s", VcrLabelNameKey, name),
+			Value: fmt.Sprintf("%s=%s", runtime.VcrLabelNameKey, name),
 		},
 	}
 
@@ -139,6 +159,40 @@ func (d *Docker) FindByName(name string) (string, error) {
 	return "", runtime.ErrContainerNotFound
 }
 
+func (d *Docker) ListContainers(ctx context.Context) ([]runtime.ContainerInfo, error) {
+	args := filters.NewArgs(filters.Arg("label", fmt.Sprintf("%s=%s", runtime.VcrLabelAppKey, runtime.VcrLabelAppValue)))
+	containersList, err := d.client.ContainerList(ctx, types.ContainerListOptions{
+		All:     true,
+		Filters: args,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make([]runtime.ContainerInfo, 0, len(containersList))
+	for _, c := range containersList {
+		info := runtime.ContainerInfo{
+			Id:      c.ID,
+			Name:    c.Labels[runtime.VcrLabelNameKey],
+			Running: c.State == "running",
+			Created: time.Unix(c.Created, 0),
+		}
+
+		if !info.Running {
+			inspect, err := d.client.ContainerInspect(ctx, c.ID)
+			if err != nil {
+				log.Warn().Err(err).Msgf("could not inspect container %s", c.ID)
+			} else if inspect.State != nil {
+				info.Finished, _ = time.Parse(time.RFC3339Nano, inspect.State.FinishedAt)
+			}
+		}
+
+		ret = append(ret, info)
+	}
+
+	return ret, nil
+}
+
 func (d *Docker) DeleteContainer(ctx context.Context, id string) error {
 	opts := types.ContainerRemoveOptions{
 		RemoveVolumes: false,
@@ -149,15 +203,33 @@ func (d *Docker) DeleteContainer(ctx context.Context, id string) error {
 
 }
 
-func (d *Docker) KillContainer(ctx context.Context, id string) error {
+func (d *Docker) Wait(ctx context.Context, id string) <-chan runtime.ExitEvent {
+	ret := make(chan runtime.ExitEvent, 1)
+	go func() {
+		respChan, errChan := d.client.ContainerWait(ctx, id, container.WaitConditionNotRunning)
+		select {
+		case resp := <-respChan:
+			event := runtime.ExitEvent{ExitCode: resp.StatusCode}
+			if resp.Error != nil {
+				event.Err = errors.New(resp.Error.Message)
+			}
+			ret <- event
+		case err := <-errChan:
+			ret <- runtime.ExitEvent{ExitCode: -1, Err: err}
+		}
+	}()
+	return ret
+}
+
+func (d *Docker) KillContainer(ctx context.Context, id string, signal string) error {
 	ctxTimeout, cancel := context.WithTimeout(ctx, 30*time.Second)
 	defer cancel()
 
-	err := d.client.ContainerKill(ctxTimeout, id, "SIGKILL")
+	err := d.client.ContainerKill(ctxTimeout, id, signal)
 	if err != nil {
-		log.Error().Err(err).Msgf("could not kill container %s", id)
+		log.Error().Err(err).Msgf("could not send %s to container %s", signal, id)
 		return err
 	}
-	log.Info().Msgf("Container %s killed", id)
+	log.Info().Msgf("Sent %s to container %s", signal, id)
 	return nil
 }
@@ -0,0 +1,342 @@
+package kubernetes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+	"vcr/internal/config"
+	"vcr/internal/runtime"
+
+	"github.com/rs/zerolog/log"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+const (
+	// VcrAnnotationNameKey holds the unmodified name of the programming, label values are restricted.
+	VcrAnnotationNameKey = "vcr/name"
+
+	containerName = "recorder"
+	volumeName    = "recordings"
+
+	waitPollInterval = 2 * time.Second
+)
+
+var invalidNameChars = regexp.MustCompile(`[^a-z0-9-]+`)
+var invalidLabelChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// Kubernetes creates a Job per recording. The name of the Job is used as id of the container.
+type Kubernetes struct {
+	client    kubernetes.Interface
+	namespace string
+	pvc       string
+}
+
+// NewKubernetesClient builds a client from the kubeconfig or the in-cluster config.
+func NewKubernetesClient(conf config.KubernetesConfig) (*Kubernetes, error) {
+	var restConf *rest.Config
+	var err error
+	if len(conf.Kubeconfig) > 0 {
+		restConf, err = clientcmd.BuildConfigFromFlags("", conf.Kubeconfig)
+	} else {
+		restConf, err = rest.InClusterConfig()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := kubernetes.NewForConfig(restConf)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewKubernetesRuntime(client, conf.Namespace, conf.PersistentVolumeClaim)
+}
+
+func NewKubernetesRuntime(client kubernetes.Interface, namespace string, pvc string) (*Kubernetes, error) {
+	if client == nil {
+		return nil, errors.New("no client provided")
+	}
+
+	if len(namespace) == 0 {
+		return nil, errors.New("empty namespace provided")
+	}
+
+	return &Kubernetes{
+		client:    client,
+		namespace: namespace,
+		pvc:       pvc,
+	}, nil
+}
+
+// Pull is a no-op, images are pulled by the kubelet.
+func (k *Kubernetes) Pull(_ context.Context, _ string) error {
+	return nil
+}
+
+// labelValue returns the name of the programming restricted to the characters and length allowed for label values.
+func labelValue(name string) string {
+	value := invalidLabelChars.ReplaceAllString(name, "_")
+	if len(value) > 63 {
+		value = value[:63]
+	}
+	return strings.Trim(value, "._-")
+}
+
+// jobName returns a unique name for a Job of the programming.
+func jobName(name string) string {
+	prefix := invalidNameChars.ReplaceAllString(strings.ToLower(name), "-")
+	if len(prefix) > 40 {
+		prefix = prefix[:40]
+	}
+	prefix = strings.Trim(prefix, "-")
+	return fmt.Sprintf("vcr-%s-%x", prefix, time.Now().UnixNano())
+}
+
+func (k *Kubernetes) Run(ctx context.Context, name string, conf config.ContainerConfig) (string, error) {
+	var backoffLimit int32
+	container := corev1.Container{
+		Name:  containerName,
+		Image: conf.Image,
+		Args:  conf.Args,
+	}
+
+	podSpec := corev1.PodSpec{
+		RestartPolicy: corev1.RestartPolicyNever,
+		Containers:    []corev1.Container{container},
+	}
+
+	if len(k.pvc) > 0 && conf.Mount != nil {
+		mountPath := conf.Mount.ContainerPath
+		if !path.IsAbs(mountPath) {
+			mountPath = runtime.DefaultMountPath
+			podSpec.Containers[0].WorkingDir = runtime.DefaultMountPath
+		}
+		podSpec.Containers[0].VolumeMounts = []corev1.VolumeMount{
+			{
+				Name:      volumeName,
+				MountPath: mountPath,
+			},
+		}
+		podSpec.Volumes = []corev1.Volume{
+			{
+				Name: volumeName,
+				VolumeSource: corev1.VolumeSource{
+					PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+						ClaimName: k.pvc,
+					},
+				},
+			},
+		}
+	}
+
+	jobLabels := map[string]string{
+		runtime.VcrLabelNameKey: labelValue(name),
+		runtime.VcrLabelAppKey:  runtime.VcrLabelAppValue,
+	}
+	annotations := map[string]string{
+		VcrAnnotationNameKey: name,
+	}
+
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        jobName(name),
+			Namespace:   k.namespace,
+			Labels:      jobLabels,
+			Annotations: annotations,
+		},
+		Spec: batchv1.JobSpec{
+			// retries are handled by vcr
+			BackoffLimit: &backoffLimit,
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels:      jobLabels,
+					Annotations: annotations,
+				},
+				Spec: podSpec,
+			},
+		},
+	}
+
+	created, err := k.client.BatchV1().Jobs(k.namespace).Create(ctx, job, metav1.CreateOptions{})
+	if err != nil {
+		return "", err
+	}
+	return created.Name, nil
+}
+
+func (k *Kubernetes) listJobs(ctx context.Context, selector map[string]string) ([]batchv1.Job, error) {
+	jobs, err := k.client.BatchV1().Jobs(k.namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: labels.SelectorFromSet(selector).String(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return jobs.Items, nil
+}
+
+func (k *Kubernetes) FindByName(name string) (string, error) {
+	jobs, err := k.listJobs(context.Background(), map[string]string{runtime.VcrLabelNameKey: labelValue(name)})
+	if err != nil {
+		return "", err
+	}
+
+	var latest *batchv1.Job
+	for i := range jobs {
+		job := &jobs[i]
+		if job.Annotations[VcrAnnotationNameKey] != name {
+			continue
+		}
+		if latest == nil || job.CreationTimestamp.After(latest.CreationTimestamp.Time) {
+			latest = job
+		}
+	}
+
+	if latest == nil {
+		return "", runtime.ErrContainerNotFound
+	}
+	return latest.Name, nil
+}
+
+// finished returns whether the Job has completed, failed or has been stopped, and the time it finished.
+func finished(job *batchv1.Job) (bool, time.Time) {
+	for _, condition := range job.Status.Conditions {
+		if (condition.Type == batchv1.JobComplete || condition.Type == batchv1.JobFailed) && condition.Status == corev1.ConditionTrue {
+			return true, condition.LastTransitionTime.Time
+		}
+	}
+
+	if job.Spec.Suspend != nil && *job.Spec.Suspend && job.Status.Active == 0 {
+		for _, condition := range job.Status.Conditions {
+			if condition.Type == batchv1.JobSuspended {
+				return true, condition.LastTransitionTime.Time
+			}
+		}
+		return true, time.Time{}
+	}
+
+	return false, time.Time{}
+}
+
+func (k *Kubernetes) ListContainers(ctx context.Context) ([]runtime.ContainerInfo, error) {
+	jobs, err := k.listJobs(ctx, map[string]string{runtime.VcrLabelAppKey: runtime.VcrLabelAppValue})
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make([]runtime.ContainerInfo, 0, len(jobs))
+	for i := range jobs {
+		job := &jobs[i]
+		done, finishedAt := finished(job)
+		ret = append(ret, runtime.ContainerInfo{
+			Id:       job.Name,
+			Name:     job.Annotations[VcrAnnotationNameKey],
+			Running:  !done,
+			Created:  job.CreationTimestamp.Time,
+			Finished: finishedAt,
+		})
+	}
+	return ret, nil
+}
+
+// DeleteContainer deletes the Job including its pods.
+func (k *Kubernetes) DeleteContainer(ctx context.Context, id string) error {
+	propagation := metav1.DeletePropagationBackground
+	return k.client.BatchV1().Jobs(k.namespace).Delete(ctx, id, metav1.DeleteOptions{
+		PropagationPolicy: &propagation,
+	})
+}
+
+// KillContainer stops the Job. Pods can not receive arbitrary signals, SIGKILL deletes the pods of the Job
+// immediately, all other signals suspend the Job which terminates its pods gracefully with SIGTERM.
+func (k *Kubernetes) KillContainer(ctx context.Context, id string, signal string) error {
+	if signal != runtime.SignalKill {
+		patch := []byte(`{"spec":{"suspend":true}}`)
+		_, err := k.client.BatchV1().Jobs(k.namespace).Patch(ctx, id, types.MergePatchType, patch, metav1.PatchOptions{})
+		if err != nil {
+			log.Error().Err(err).Msgf("could not suspend job %s", id)
+			return err
+		}
+		log.Info().Msgf("Suspended job %s", id)
+		return nil
+	}
+
+	var gracePeriod int64
+	err := k.client.CoreV1().Pods(k.namespace).DeleteCollection(ctx, metav1.DeleteOptions{
+		GracePeriodSeconds: &gracePeriod,
+	}, metav1.ListOptions{
+		LabelSelector: labels.SelectorFromSet(map[string]string{"job-name": id}).String(),
+	})
+	if err != nil {
+		log.Error().Err(err).Msgf("could not kill pods of job %s", id)
+		return err
+	}
+	log.Info().Msgf("Killed pods of job %s", id)
+	return nil
+}
+
+// exitCode returns the exit code of the terminated recorder container of the Job, if any.
+func (k *Kubernetes) exitCode(ctx context.Context, id string) (int64, bool) {
+	pods, err := k.client.CoreV1().Pods(k.namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: labels.SelectorFromSet(map[string]string{"job-name": id}).String(),
+	})
+	if err != nil {
+		return 0, false
+	}
+
+	for _, pod := range pods.Items {
+		for _, status := range pod.Status.ContainerStatuses {
+			if status.Name == containerName && status.State.Terminated != nil {
+				return int64(status.State.Terminated.ExitCode), true
+			}
+		}
+	}
+	return 0, false
+}
+
+// Wait polls the Job until it has finished. If the exit code of the container is not available anymore, e.g. because
+// its pod has been deleted, 0 is reported for completed and -1 for failed or stopped Jobs.
+func (k *Kubernetes) Wait(ctx context.Context, id string) <-chan runtime.ExitEvent {
+	ret := make(chan runtime.ExitEvent, 1)
+	go func() {
+		ticker := time.NewTicker(waitPollInterval)
+		defer ticker.Stop()
+
+		for {
+			job, err := k.client.BatchV1().Jobs(k.namespace).Get(ctx, id, metav1.GetOptions{})
+			if err != nil {
+				ret <- runtime.ExitEvent{ExitCode: -1, Err: err}
+				return
+			}
+
+			if done, _ := finished(job); done {
+				if exitCode, ok := k.exitCode(ctx, id); ok {
+					ret <- runtime.ExitEvent{ExitCode: exitCode}
+				} else if job.Status.Succeeded > 0 {
+					ret <- runtime.ExitEvent{ExitCode: 0}
+				} else {
+					ret <- runtime.ExitEvent{ExitCode: -1}
+				}
+				return
+			}
+
+			select {
+			case <-ctx.Done():
+				ret <- runtime.ExitEvent{ExitCode: -1, Err: ctx.Err()}
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return ret
+}
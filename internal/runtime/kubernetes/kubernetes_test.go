@@ -0,0 +1,236 @@
+package kubernetes
+
+import (
+	"context"
+	"testing"
+	"time"
+	"vcr/internal/config"
+	"vcr/internal/runtime"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sruntime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+const testNamespace = "recordings"
+
+func newTestRuntime(t *testing.T, pvc string, objects ...k8sruntime.Object) (*Kubernetes, *fake.Clientset) {
+	t.Helper()
+	client := fake.NewSimpleClientset(objects...)
+	k, err := NewKubernetesRuntime(client, testNamespace, pvc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k, client
+}
+
+// testJob returns a Job that has been created by Run for the programming.
+func testJob(id, name string, created time.Time) *batchv1.Job {
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              id,
+			Namespace:         testNamespace,
+			CreationTimestamp: metav1.NewTime(created),
+			Labels: map[string]string{
+				runtime.VcrLabelNameKey: labelValue(name),
+				runtime.VcrLabelAppKey:  runtime.VcrLabelAppValue,
+			},
+			Annotations: map[string]string{VcrAnnotationNameKey: name},
+		},
+	}
+}
+
+func TestKubernetes_Run(t *testing.T) {
+	tests := []struct {
+		name          string
+		pvc           string
+		containerPath string
+		wantMountPath string
+		wantWorkDir   string
+	}{
+		{name: "without pvc", containerPath: "/data"},
+		{name: "absolute path", pvc: "vcr-recordings", containerPath: "/data", wantMountPath: "/data"},
+		{name: "relative path", pvc: "vcr-recordings", containerPath: "data", wantMountPath: runtime.DefaultMountPath, wantWorkDir: runtime.DefaultMountPath},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k, client := newTestRuntime(t, tt.pvc)
+			conf := config.ContainerConfig{
+				Image: "ghcr.io/example/recorder",
+				Args:  []string{"--url", "https://example.com"},
+				Mount: &config.Mount{HostPath: "/srv/recordings", ContainerPath: tt.containerPath},
+			}
+
+			id, err := k.Run(context.Background(), "Late Night: News", conf)
+			if err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+
+			job, err := client.BatchV1().Jobs(testNamespace).Get(context.Background(), id, metav1.GetOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			wantLabels := map[string]string{runtime.VcrLabelNameKey: "Late_Night_News", runtime.VcrLabelAppKey: runtime.VcrLabelAppValue}
+			for key, value := range wantLabels {
+				if job.Labels[key] != value || job.Spec.Template.Labels[key] != value {
+					t.Errorf("label %s = %q (pod %q), want %q", key, job.Labels[key], job.Spec.Template.Labels[key], value)
+				}
+			}
+			if job.Annotations[VcrAnnotationNameKey] != "Late Night: News" {
+				t.Errorf("annotation %s = %q, want the unmodified name", VcrAnnotationNameKey, job.Annotations[VcrAnnotationNameKey])
+			}
+			if job.Spec.BackoffLimit == nil || *job.Spec.BackoffLimit != 0 {
+				t.Errorf("backoff limit = %v, want 0", job.Spec.BackoffLimit)
+			}
+
+			pod := job.Spec.Template.Spec
+			container := pod.Containers[0]
+			if container.WorkingDir != tt.wantWorkDir {
+				t.Errorf("working dir = %q, want %q", container.WorkingDir, tt.wantWorkDir)
+			}
+			if len(tt.pvc) == 0 {
+				if len(pod.Volumes) != 0 || len(container.VolumeMounts) != 0 {
+					t.Errorf("volumes = %v, mounts = %v, want none without pvc", pod.Volumes, container.VolumeMounts)
+				}
+				return
+			}
+			if len(pod.Volumes) != 1 || pod.Volumes[0].PersistentVolumeClaim == nil || pod.Volumes[0].PersistentVolumeClaim.ClaimName != tt.pvc {
+				t.Errorf("volumes = %+v, want pvc %s", pod.Volumes, tt.pvc)
+			}
+			if len(container.VolumeMounts) != 1 || container.VolumeMounts[0].MountPath != tt.wantMountPath {
+				t.Errorf("mounts = %+v, want mount at %s", container.VolumeMounts, tt.wantMountPath)
+			}
+		})
+	}
+}
+
+func TestKubernetes_FindByNameWithSameLabel(t *testing.T) {
+	created := time.Date(2024, 3, 1, 20, 0, 0, 0, time.UTC)
+	// both names are sanitized to the same label value
+	k, _ := newTestRuntime(t, "",
+		testJob("vcr-news-1", "news: late", created),
+		testJob("vcr-news-2", "news/late", created.Add(time.Hour)),
+		testJob("vcr-news-3", "news: late", created.Add(time.Minute)),
+	)
+	if labelValue("news: late") != labelValue("news/late") {
+		t.Fatal("names are expected to share a label value")
+	}
+
+	id, err := k.FindByName("news: late")
+	if err != nil {
+		t.Fatalf("FindByName() error = %v", err)
+	}
+	if id != "vcr-news-3" {
+		t.Errorf("FindByName() = %s, want latest job of the programming vcr-news-3", id)
+	}
+
+	if _, err := k.FindByName("news late"); err != runtime.ErrContainerNotFound {
+		t.Errorf("FindByName() error = %v, want %v", err, runtime.ErrContainerNotFound)
+	}
+}
+
+func TestKubernetes_KillContainer(t *testing.T) {
+	tests := []struct {
+		signal      string
+		wantSuspend bool
+	}{
+		{signal: "SIGINT", wantSuspend: true},
+		{signal: runtime.SignalKill},
+	}
+	for _, tt := range tests {
+		t.Run(tt.signal, func(t *testing.T) {
+			k, client := newTestRuntime(t, "", testJob("vcr-news-1", "news", time.Now()))
+			// the object tracker of the fake does not support deleting collections
+			client.PrependReactor("delete-collection", "pods", func(action k8stesting.Action) (bool, k8sruntime.Object, error) {
+				return true, nil, nil
+			})
+
+			if err := k.KillContainer(context.Background(), "vcr-news-1", tt.signal); err != nil {
+				t.Fatalf("KillContainer() error = %v", err)
+			}
+
+			job, err := client.BatchV1().Jobs(testNamespace).Get(context.Background(), "vcr-news-1", metav1.GetOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			suspended := job.Spec.Suspend != nil && *job.Spec.Suspend
+			if suspended != tt.wantSuspend {
+				t.Errorf("suspended = %v, want %v", suspended, tt.wantSuspend)
+			}
+
+			var deleted *k8stesting.DeleteCollectionActionImpl
+			for _, action := range client.Actions() {
+				if a, ok := action.(k8stesting.DeleteCollectionActionImpl); ok {
+					deleted = &a
+				}
+			}
+			if tt.wantSuspend {
+				if deleted != nil {
+					t.Errorf("pods deleted for signal %s", tt.signal)
+				}
+				return
+			}
+			if deleted == nil {
+				t.Fatal("pods of the job have not been deleted")
+			}
+			if selector := deleted.GetListRestrictions().Labels.String(); selector != "job-name=vcr-news-1" {
+				t.Errorf("pods deleted with selector %q, want job-name=vcr-news-1", selector)
+			}
+		})
+	}
+}
+
+func TestFinished(t *testing.T) {
+	suspended := true
+	at := time.Date(2024, 3, 1, 21, 0, 0, 0, time.UTC)
+	condition := func(conditionType batchv1.JobConditionType) batchv1.JobCondition {
+		return batchv1.JobCondition{Type: conditionType, Status: corev1.ConditionTrue, LastTransitionTime: metav1.NewTime(at)}
+	}
+
+	tests := []struct {
+		name         string
+		job          batchv1.Job
+		wantFinished bool
+		wantAt       time.Time
+	}{
+		{
+			name: "running",
+			job:  batchv1.Job{Status: batchv1.JobStatus{Active: 1}},
+		},
+		{
+			name:         "completed",
+			job:          batchv1.Job{Status: batchv1.JobStatus{Conditions: []batchv1.JobCondition{condition(batchv1.JobComplete)}}},
+			wantFinished: true,
+			wantAt:       at,
+		},
+		{
+			name: "suspended with active pods",
+			job:  batchv1.Job{Spec: batchv1.JobSpec{Suspend: &suspended}, Status: batchv1.JobStatus{Active: 1}},
+		},
+		{
+			name: "suspended",
+			job: batchv1.Job{
+				Spec:   batchv1.JobSpec{Suspend: &suspended},
+				Status: batchv1.JobStatus{Conditions: []batchv1.JobCondition{condition(batchv1.JobSuspended)}},
+			},
+			wantFinished: true,
+			wantAt:       at,
+		},
+		{
+			name:         "suspended without condition",
+			job:          batchv1.Job{Spec: batchv1.JobSpec{Suspend: &suspended}},
+			wantFinished: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			done, finishedAt := finished(&tt.job)
+			if done != tt.wantFinished || !finishedAt.Equal(tt.wantAt) {
+				t.Errorf("finished() = %v, %v, want %v, %v", done, finishedAt, tt.wantFinished, tt.wantAt)
+			}
+		})
+	}
+}
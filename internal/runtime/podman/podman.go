@@ -0,0 +1,381 @@
+package podman
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+	"vcr/internal/config"
+	"vcr/internal/runtime"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// apiVersion is the version of the libpod API, it's supported by Podman 4 and newer.
+	apiVersion = "v4.0.0"
+	// defaultRegistry qualifies short image names, Podman refuses to resolve them without a terminal.
+	defaultRegistry = "docker.io"
+
+	containerHostEnv = "CONTAINER_HOST"
+	rootfulSocket    = "/run/podman/podman.sock"
+)
+
+// apiError is the body of error responses of the libpod API.
+type apiError struct {
+	Cause    string `json:"cause"`
+	Message  string `json:"message"`
+	Response int    `json:"response"`
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("podman api returned %d: %s", e.Response, e.Message)
+}
+
+// Podman talks to the libpod API of a rootless or rootful Podman. Containers are found by their labels like the Docker
+// runtime, but libpod expects the label filters as JSON and reports the exit time of containers in their listing.
+type Podman struct {
+	client  *http.Client
+	baseUrl string
+}
+
+// NewPodmanClient connects to the configured socket or discovers the socket of a rootless or rootful Podman.
+func NewPodmanClient(conf config.PodmanConfig) (*Podman, error) {
+	socket, err := discoverSocket(conf.Socket)
+	if err != nil {
+		return nil, err
+	}
+	log.Info().Msgf("Using podman socket %s", socket)
+
+	client := &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var dialer net.Dialer
+				return dialer.DialContext(ctx, "unix", socket)
+			},
+		},
+	}
+
+	// the host is ignored when dialing the socket
+	return NewPodmanRuntime(client, "http://d")
+}
+
+func NewPodmanRuntime(client *http.Client, baseUrl string) (*Podman, error) {
+	if client == nil {
+		return nil, errors.New("no client provided")
+	}
+
+	if len(baseUrl) == 0 {
+		return nil, errors.New("empty base url provided")
+	}
+
+	return &Podman{
+		client:  client,
+		baseUrl: strings.TrimSuffix(baseUrl, "/") + "/" + apiVersion + "/libpod",
+	}, nil
+}
+
+// discoverSocket returns the configured socket, the socket of CONTAINER_HOST or the first existing default socket,
+// preferring the rootless socket of the current user.
+func discoverSocket(configured string) (string, error) {
+	if len(configured) > 0 {
+		return configured, nil
+	}
+
+	if host := os.Getenv(containerHostEnv); len(host) > 0 {
+		parsed, err := url.Parse(host)
+		if err != nil {
+			return "", fmt.Errorf("invalid %s: %w", containerHostEnv, err)
+		}
+		if parsed.Scheme != "unix" {
+			return "", fmt.Errorf("unsupported scheme %q of %s", parsed.Scheme, containerHostEnv)
+		}
+		return parsed.Path, nil
+	}
+
+	var candidates []string
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); len(dir) > 0 {
+		candidates = append(candidates, filepath.Join(dir, "podman", "podman.sock"))
+	}
+	if uid := os.Getuid(); uid > 0 {
+		candidates = append(candidates, fmt.Sprintf("/run/user/%d/podman/podman.sock", uid))
+	}
+	candidates = append(candidates, rootfulSocket)
+
+	for _, candidate := range candidates {
+		if info, err := os.Stat(candidate); err == nil && info.Mode()&os.ModeSocket != 0 {
+			return candidate, nil
+		}
+	}
+	return "", fmt.Errorf("no podman socket found, tried %s", strings.Join(candidates, ", "))
+}
+
+// qualifyImage prefixes short image names with the default registry, as the Docker daemon would.
+func qualifyImage(image string) string {
+	first, _, found := strings.Cut(image, "/")
+	if found && (strings.ContainsAny(first, ".:") || first == "localhost") {
+		return image
+	}
+	if !found {
+		return defaultRegistry + "/library/" + image
+	}
+	return defaultRegistry + "/" + image
+}
+
+// labelFilter returns the filters parameter that matches containers with the given label.
+func labelFilter(key, value string) string {
+	filters, _ := json.Marshal(map[string][]string{
+		"label": {fmt.Sprintf("%s=%s", key, value)},
+	})
+	return string(filters)
+}
+
+// do sends the request and decodes the response into ret, unless ret is nil. Responses with an error status are
+// returned as apiError.
+func (p *Podman) do(ctx context.Context, method string, endpoint string, params url.Values, body any, ret any) error {
+	var reader io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(encoded)
+	}
+
+	target := p.baseUrl + endpoint
+	if len(params) > 0 {
+		target += "?" + params.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, target, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		apiErr := &apiError{}
+		if err := json.NewDecoder(resp.Body).Decode(apiErr); err != nil || len(apiErr.Message) == 0 {
+			apiErr.Message = http.StatusText(resp.StatusCode)
+		}
+		apiErr.Response = resp.StatusCode
+		return apiErr
+	}
+
+	if ret == nil {
+		_, err = io.Copy(io.Discard, resp.Body)
+		return err
+	}
+	return json.NewDecoder(resp.Body).Decode(ret)
+}
+
+func (p *Podman) Pull(ctx context.Context, image string) error {
+	params := url.Values{}
+	params.Set("reference", qualifyImage(image))
+	params.Set("policy", "newer")
+	params.Set("quiet", "true")
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseUrl+"/images/pull?"+params.Encode(), nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return &apiError{Message: http.StatusText(resp.StatusCode), Response: resp.StatusCode}
+	}
+
+	// the status of the pull is only known after the stream of reports has ended
+	type Report struct {
+		Stream string `json:"stream"`
+		Error  string `json:"error"`
+		Id     string `json:"id"`
+	}
+
+	decode := json.NewDecoder(resp.Body)
+	for {
+		var report Report
+		if err := decode.Decode(&report); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		if len(report.Error) > 0 {
+			return errors.New(report.Error)
+		}
+	}
+}
+
+type mountSpec struct {
+	Destination string   `json:"destination"`
+	Source      string   `json:"source"`
+	Type        string   `json:"type"`
+	Options     []string `json:"options,omitempty"`
+}
+
+type createSpec struct {
+	Image   string            `json:"image"`
+	Command []string          `json:"command,omitempty"`
+	Labels  map[string]string `json:"labels"`
+	Mounts  []mountSpec       `json:"mounts,omitempty"`
+	WorkDir string            `json:"work_dir,omitempty"`
+}
+
+func (p *Podman) Run(ctx context.Context, name string, conf config.ContainerConfig) (string, error) {
+	spec := createSpec{
+		Image:   qualifyImage(conf.Image),
+		Command: conf.Args,
+		Labels: map[string]string{
+			runtime.VcrLabelNameKey: name,
+			runtime.VcrLabelAppKey:  runtime.VcrLabelAppValue,
+		},
+	}
+
+	if conf.Mount != nil && len(conf.Mount.HostPath) > 0 {
+		destination := conf.Mount.ContainerPath
+		if !path.IsAbs(destination) {
+			destination = runtime.DefaultMountPath
+			spec.WorkDir = runtime.DefaultMountPath
+		}
+		spec.Mounts = []mountSpec{
+			{
+				Destination: destination,
+				Source:      conf.Mount.HostPath,
+				Type:        "bind",
+				Options:     []string{"rbind"},
+			},
+		}
+	}
+
+	var created struct {
+		Id       string   `json:"Id"`
+		Warnings []string `json:"Warnings"`
+	}
+	if err := p.do(ctx, http.MethodPost, "/containers/create", nil, spec, &created); err != nil {
+		return "", err
+	}
+	for _, warning := range created.Warnings {
+		log.Warn().Msgf("podman: %s", warning)
+	}
+
+	return created.Id, p.do(ctx, http.MethodPost, "/containers/"+created.Id+"/start", nil, nil, nil)
+}
+
+type listedContainer struct {
+	Id       string            `json:"Id"`
+	Labels   map[string]string `json:"Labels"`
+	State    string            `json:"State"`
+	Created  time.Time         `json:"Created"`
+	ExitedAt int64             `json:"ExitedAt"`
+}
+
+func (p *Podman) listContainers(ctx context.Context, key, value string) ([]listedContainer, error) {
+	params := url.Values{}
+	params.Set("all", "true")
+	params.Set("filters", labelFilter(key, value))
+
+	var containers []listedContainer
+	if err := p.do(ctx, http.MethodGet, "/containers/json", params, nil, &containers); err != nil {
+		return nil, err
+	}
+	return containers, nil
+}
+
+func (p *Podman) FindByName(name string) (string, error) {
+	containers, err := p.listContainers(context.Background(), runtime.VcrLabelNameKey, name)
+	if err != nil {
+		return "", err
+	}
+
+	var latest *listedContainer
+	for i := range containers {
+		if latest == nil || containers[i].Created.After(latest.Created) {
+			latest = &containers[i]
+		}
+	}
+
+	if latest == nil {
+		return "", runtime.ErrContainerNotFound
+	}
+	return latest.Id, nil
+}
+
+func (p *Podman) ListContainers(ctx context.Context) ([]runtime.ContainerInfo, error) {
+	containers, err := p.listContainers(ctx, runtime.VcrLabelAppKey, runtime.VcrLabelAppValue)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make([]runtime.ContainerInfo, 0, len(containers))
+	for _, c := range containers {
+		info := runtime.ContainerInfo{
+			Id:      c.Id,
+			Name:    c.Labels[runtime.VcrLabelNameKey],
+			Running: c.State == "running",
+			Created: c.Created,
+		}
+		if !info.Running && c.ExitedAt > 0 {
+			info.Finished = time.Unix(c.ExitedAt, 0)
+		}
+		ret = append(ret, info)
+	}
+	return ret, nil
+}
+
+func (p *Podman) DeleteContainer(ctx context.Context, id string) error {
+	return p.do(ctx, http.MethodDelete, "/containers/"+id, nil, nil, nil)
+}
+
+// Wait blocks on the wait endpoint, which waits for the container to be stopped or exited if no condition is given.
+func (p *Podman) Wait(ctx context.Context, id string) <-chan runtime.ExitEvent {
+	ret := make(chan runtime.ExitEvent, 1)
+	go func() {
+		// Podman responds with the bare exit code
+		var exitCode int64
+		if err := p.do(ctx, http.MethodPost, "/containers/"+id+"/wait", nil, nil, &exitCode); err != nil {
+			ret <- runtime.ExitEvent{ExitCode: -1, Err: err}
+			return
+		}
+		ret <- runtime.ExitEvent{ExitCode: exitCode}
+	}()
+	return ret
+}
+
+func (p *Podman) KillContainer(ctx context.Context, id string, signal string) error {
+	ctxTimeout, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	params := url.Values{}
+	params.Set("signal", signal)
+	err := p.do(ctxTimeout, http.MethodPost, "/containers/"+id+"/kill", params, nil, nil)
+	if err != nil {
+		log.Error().Err(err).Msgf("could not send %s to container %s", signal, id)
+		return err
+	}
+	log.Info().Msgf("Sent %s to container %s", signal, id)
+	return nil
+}
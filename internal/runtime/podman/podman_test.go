@@ -0,0 +1,226 @@
+package podman
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"vcr/internal/config"
+	"vcr/internal/runtime"
+)
+
+const apiPrefix = "/" + apiVersion + "/libpod"
+
+// newTestRuntime returns a runtime that talks to a server with the given handlers, keyed by method and path of the
+// libpod endpoint, e.g. "GET /containers/json".
+func newTestRuntime(t *testing.T, handlers map[string]http.HandlerFunc) *Podman {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handler, ok := handlers[r.Method+" "+r.URL.Path[len(apiPrefix):]]
+		if !ok {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			http.NotFound(w, r)
+			return
+		}
+		handler(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	p, err := NewPodmanRuntime(server.Client(), server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func writeJson(t *testing.T, w http.ResponseWriter, status int, value any) {
+	t.Helper()
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(value); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestQualifyImage(t *testing.T) {
+	tests := []struct {
+		image string
+		want  string
+	}{
+		{image: "alpine", want: "docker.io/library/alpine"},
+		{image: "alpine:3.19", want: "docker.io/library/alpine:3.19"},
+		{image: "example/recorder", want: "docker.io/example/recorder"},
+		{image: "ghcr.io/example/recorder", want: "ghcr.io/example/recorder"},
+		{image: "registry:5000/recorder", want: "registry:5000/recorder"},
+		{image: "localhost/recorder", want: "localhost/recorder"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.image, func(t *testing.T) {
+			if got := qualifyImage(tt.image); got != tt.want {
+				t.Errorf("qualifyImage() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPodman_Run(t *testing.T) {
+	var spec createSpec
+	started := false
+	p := newTestRuntime(t, map[string]http.HandlerFunc{
+		"POST /containers/create": func(w http.ResponseWriter, r *http.Request) {
+			if err := json.NewDecoder(r.Body).Decode(&spec); err != nil {
+				t.Error(err)
+			}
+			writeJson(t, w, http.StatusCreated, map[string]any{"Id": "abc", "Warnings": []string{}})
+		},
+		"POST /containers/abc/start": func(w http.ResponseWriter, r *http.Request) {
+			started = true
+			w.WriteHeader(http.StatusNoContent)
+		},
+	})
+
+	conf := config.ContainerConfig{
+		Image: "recorder",
+		Args:  []string{"--url", "https://example.com"},
+		Mount: &config.Mount{HostPath: "/srv/recordings", ContainerPath: "."},
+	}
+	id, err := p.Run(context.Background(), "news", conf)
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if id != "abc" || !started {
+		t.Errorf("Run() = %s, started = %v, want started container abc", id, started)
+	}
+
+	if spec.Image != "docker.io/library/recorder" {
+		t.Errorf("image = %s, want qualified image", spec.Image)
+	}
+	if spec.Labels[runtime.VcrLabelNameKey] != "news" || spec.Labels[runtime.VcrLabelAppKey] != runtime.VcrLabelAppValue {
+		t.Errorf("labels = %v", spec.Labels)
+	}
+	if len(spec.Mounts) != 1 || spec.Mounts[0].Destination != runtime.DefaultMountPath || spec.Mounts[0].Source != "/srv/recordings" {
+		t.Errorf("mounts = %+v, want /srv/recordings at %s", spec.Mounts, runtime.DefaultMountPath)
+	}
+	if spec.WorkDir != runtime.DefaultMountPath {
+		t.Errorf("work dir = %s, want %s", spec.WorkDir, runtime.DefaultMountPath)
+	}
+}
+
+func TestPodman_LabelFilter(t *testing.T) {
+	created := time.Date(2024, 3, 1, 20, 0, 0, 0, time.UTC)
+	var filters []string
+	p := newTestRuntime(t, map[string]http.HandlerFunc{
+		"GET /containers/json": func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Query().Get("all") != "true" {
+				t.Errorf("all = %q, want stopped containers to be listed", r.URL.Query().Get("all"))
+			}
+			var filter map[string][]string
+			if err := json.Unmarshal([]byte(r.URL.Query().Get("filters")), &filter); err != nil {
+				t.Errorf("filters are not json: %v", err)
+			}
+			filters = append(filters, filter["label"]...)
+
+			writeJson(t, w, http.StatusOK, []map[string]any{
+				{"Id": "old", "Labels": map[string]string{runtime.VcrLabelNameKey: "news"}, "State": "exited", "Created": created, "ExitedAt": created.Add(time.Hour).Unix()},
+				{"Id": "new", "Labels": map[string]string{runtime.VcrLabelNameKey: "news"}, "State": "running", "Created": created.Add(24 * time.Hour)},
+			})
+		},
+	})
+
+	id, err := p.FindByName("news")
+	if err != nil {
+		t.Fatalf("FindByName() error = %v", err)
+	}
+	if id != "new" {
+		t.Errorf("FindByName() = %s, want the latest container", id)
+	}
+
+	containers, err := p.ListContainers(context.Background())
+	if err != nil {
+		t.Fatalf("ListContainers() error = %v", err)
+	}
+	if len(containers) != 2 || containers[0].Running || !containers[0].Finished.Equal(created.Add(time.Hour)) || !containers[1].Running {
+		t.Errorf("ListContainers() = %+v", containers)
+	}
+
+	want := []string{runtime.VcrLabelNameKey + "=news", runtime.VcrLabelAppKey + "=" + runtime.VcrLabelAppValue}
+	if len(filters) != 2 || filters[0] != want[0] || filters[1] != want[1] {
+		t.Errorf("label filters = %v, want %v", filters, want)
+	}
+}
+
+func TestPodman_Wait(t *testing.T) {
+	p := newTestRuntime(t, map[string]http.HandlerFunc{
+		"POST /containers/abc/wait": func(w http.ResponseWriter, r *http.Request) {
+			// the exit code is not wrapped in an object
+			_, _ = w.Write([]byte("137\n"))
+		},
+	})
+
+	select {
+	case event := <-p.Wait(context.Background(), "abc"):
+		if event.Err != nil || event.ExitCode != 137 {
+			t.Errorf("Wait() = %+v, want exit code 137", event)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Wait() did not return")
+	}
+}
+
+func TestPodman_ApiError(t *testing.T) {
+	p := newTestRuntime(t, map[string]http.HandlerFunc{
+		"POST /containers/gone/kill": func(w http.ResponseWriter, r *http.Request) {
+			writeJson(t, w, http.StatusNotFound, apiError{Cause: "no such container", Message: "no container with name or ID \"gone\" found", Response: 404})
+		},
+		"DELETE /containers/broken": func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "not json", http.StatusInternalServerError)
+		},
+	})
+
+	err := p.KillContainer(context.Background(), "gone", "SIGINT")
+	var apiErr *apiError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("KillContainer() error = %v, want apiError", err)
+	}
+	if apiErr.Response != http.StatusNotFound || apiErr.Cause != "no such container" || apiErr.Message != "no container with name or ID \"gone\" found" {
+		t.Errorf("KillContainer() error = %+v", apiErr)
+	}
+
+	err = p.DeleteContainer(context.Background(), "broken")
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("DeleteContainer() error = %v, want apiError", err)
+	}
+	if apiErr.Response != http.StatusInternalServerError || apiErr.Message != http.StatusText(http.StatusInternalServerError) {
+		t.Errorf("DeleteContainer() error = %+v, want status text for undecodable body", apiErr)
+	}
+}
+
+func TestDiscoverSocket(t *testing.T) {
+	tests := []struct {
+		name          string
+		configured    string
+		containerHost string
+		want          string
+		wantErr       bool
+	}{
+		{name: "configured", configured: "/tmp/podman.sock", containerHost: "unix:///run/podman/other.sock", want: "/tmp/podman.sock"},
+		{name: "container host", containerHost: "unix:///run/user/1000/podman/podman.sock", want: "/run/user/1000/podman/podman.sock"},
+		{name: "unsupported scheme", containerHost: "ssh://core@localhost:2222/run/podman/podman.sock", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(containerHostEnv, tt.containerHost)
+
+			got, err := discoverSocket(tt.configured)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("discoverSocket() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("discoverSocket() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,235 @@
+package process
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+	"vcr/internal/config"
+	"vcr/internal/runtime"
+
+	"github.com/rs/zerolog/log"
+)
+
+var signals = map[string]os.Signal{
+	"SIGINT":  syscall.SIGINT,
+	"SIGTERM": syscall.SIGTERM,
+	"SIGQUIT": syscall.SIGQUIT,
+	"SIGHUP":  syscall.SIGHUP,
+	"SIGKILL": syscall.SIGKILL,
+}
+
+type process struct {
+	name     string
+	cmd      *exec.Cmd
+	created  time.Time
+	finished time.Time
+	exitCode int64
+	// done is closed after the process has exited
+	done chan struct{}
+}
+
+func (p *process) running() bool {
+	select {
+	case <-p.done:
+		return false
+	default:
+		return true
+	}
+}
+
+// Process runs the recorder binary as local subprocess instead of a container. Processes are identified by their
+// PID and only known to the vcr process that started them, they can not be adopted after a restart.
+type Process struct {
+	binary string
+
+	mutex     sync.Mutex
+	processes map[string]*process
+}
+
+func NewProcessRuntime(binary string) (*Process, error) {
+	if len(binary) == 0 {
+		return nil, errors.New("empty binary provided")
+	}
+
+	return &Process{
+		binary:    binary,
+		processes: map[string]*process{},
+	}, nil
+}
+
+// Pull verifies that the binary is available, the image is ignored.
+func (p *Process) Pull(_ context.Context, _ string) error {
+	_, err := exec.LookPath(p.binary)
+	return err
+}
+
+// translateArgs replaces the container path of the mount in the arguments with its host path, as the process writes
+// to the host path directly.
+func translateArgs(conf config.ContainerConfig) []string {
+	if conf.Mount == nil || len(conf.Mount.HostPath) == 0 || len(conf.Mount.ContainerPath) == 0 {
+		return conf.Args
+	}
+
+	prefix := strings.TrimSuffix(conf.Mount.ContainerPath, "/") + "/"
+	ret := make([]string, 0, len(conf.Args))
+	for _, arg := range conf.Args {
+		if strings.HasPrefix(arg, prefix) {
+			arg = strings.TrimSuffix(conf.Mount.HostPath, "/") + "/" + strings.TrimPrefix(arg, prefix)
+		}
+		ret = append(ret, arg)
+	}
+	return ret
+}
+
+func (p *Process) Run(_ context.Context, name string, conf config.ContainerConfig) (string, error) {
+	cmd := exec.Command(p.binary, translateArgs(conf)...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if conf.Mount != nil && len(conf.Mount.HostPath) > 0 {
+		cmd.Dir = conf.Mount.HostPath
+	}
+
+	if err := cmd.Start(); err != nil {
+		return "", err
+	}
+
+	id := strconv.Itoa(cmd.Process.Pid)
+	proc := &process{
+		name:    name,
+		cmd:     cmd,
+		created: time.Now(),
+		done:    make(chan struct{}),
+	}
+
+	p.mutex.Lock()
+	p.processes[id] = proc
+	p.mutex.Unlock()
+
+	go func() {
+		if err := cmd.Wait(); err != nil {
+			log.Debug().Err(err).Msgf("process %s exited", id)
+		}
+		// processes terminated by a signal report -1
+		exitCode := int64(cmd.ProcessState.ExitCode())
+
+		p.mutex.Lock()
+		proc.exitCode = exitCode
+		proc.finished = time.Now()
+		p.mutex.Unlock()
+		close(proc.done)
+	}()
+
+	return id, nil
+}
+
+func (p *Process) find(id string) (*process, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	proc, ok := p.processes[id]
+	if !ok {
+		return nil, runtime.ErrContainerNotFound
+	}
+	return proc, nil
+}
+
+func (p *Process) FindByName(name string) (string, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	var latestId string
+	var latest *process
+	for id, proc := range p.processes {
+		if proc.name == name && (latest == nil || proc.created.After(latest.created)) {
+			latestId, latest = id, proc
+		}
+	}
+
+	if latest == nil {
+		return "", runtime.ErrContainerNotFound
+	}
+	return latestId, nil
+}
+
+func (p *Process) ListContainers(_ context.Context) ([]runtime.ContainerInfo, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	ret := make([]runtime.ContainerInfo, 0, len(p.processes))
+	for id, proc := range p.processes {
+		ret = append(ret, runtime.ContainerInfo{
+			Id:       id,
+			Name:     proc.name,
+			Running:  proc.running(),
+			Created:  proc.created,
+			Finished: proc.finished,
+		})
+	}
+	return ret, nil
+}
+
+// DeleteContainer forgets about an exited process.
+func (p *Process) DeleteContainer(_ context.Context, id string) error {
+	proc, err := p.find(id)
+	if err != nil {
+		return err
+	}
+
+	if proc.running() {
+		return fmt.Errorf("process %s is still running", id)
+	}
+
+	p.mutex.Lock()
+	delete(p.processes, id)
+	p.mutex.Unlock()
+	return nil
+}
+
+func (p *Process) KillContainer(_ context.Context, id string, signal string) error {
+	proc, err := p.find(id)
+	if err != nil {
+		return err
+	}
+
+	sig, ok := signals[signal]
+	if !ok {
+		return fmt.Errorf("unknown signal %q", signal)
+	}
+
+	if err := proc.cmd.Process.Signal(sig); err != nil {
+		log.Error().Err(err).Msgf("could not send %s to process %s", signal, id)
+		return err
+	}
+	log.Info().Msgf("Sent %s to process %s", signal, id)
+	return nil
+}
+
+func (p *Process) Wait(ctx context.Context, id string) <-chan runtime.ExitEvent {
+	ret := make(chan runtime.ExitEvent, 1)
+
+	proc, err := p.find(id)
+	if err != nil {
+		ret <- runtime.ExitEvent{ExitCode: -1, Err: err}
+		return ret
+	}
+
+	go func() {
+		select {
+		case <-proc.done:
+			p.mutex.Lock()
+			exitCode := proc.exitCode
+			p.mutex.Unlock()
+			ret <- runtime.ExitEvent{ExitCode: exitCode}
+		case <-ctx.Done():
+			ret <- runtime.ExitEvent{ExitCode: -1, Err: ctx.Err()}
+		}
+	}()
+	return ret
+}
@@ -3,15 +3,51 @@ package runtime
 import (
 	"context"
 	"errors"
+	"time"
 	"vcr/internal/config"
 )
 
 var ErrContainerNotFound = errors.New("container not found")
 
+const SignalKill = "SIGKILL"
+
+// Labels that are set on all containers created by vcr, they're used to find the containers again.
+const (
+	VcrLabelNameKey  = "vcr_name"
+	VcrLabelAppKey   = "app"
+	VcrLabelAppValue = "vcr"
+)
+
+// DefaultMountPath is used by runtimes that require absolute paths if the container path of the mount is relative.
+const DefaultMountPath = "/recordings"
+
+// ExitEvent describes the exit of a container. Err is set if waiting for the container failed, ExitCode is not
+// meaningful in that case.
+type ExitEvent struct {
+	ExitCode int64
+	Err      error
+}
+
+// ContainerInfo describes a container that has been created by vcr.
+type ContainerInfo struct {
+	Id string
+	// Name is the name of the programming the container was created for.
+	Name     string
+	Running  bool
+	Created  time.Time
+	Finished time.Time
+}
+
 type ContainerRuntime interface {
 	Pull(ctx context.Context, image string) error
 	Run(ctx context.Context, name string, conf config.ContainerConfig) (string, error)
 	FindByName(name string) (string, error)
 	DeleteContainer(ctx context.Context, id string) error
-	KillContainer(ctx context.Context, id string) error
+	// KillContainer sends the given signal, e.g. "SIGINT", to the container.
+	KillContainer(ctx context.Context, id string, signal string) error
+	// ListContainers returns all containers created by vcr, including stopped containers.
+	ListContainers(ctx context.Context) ([]ContainerInfo, error)
+	// Wait returns a channel that receives a single event as soon as the container has exited. Cancelling the context
+	// aborts waiting.
+	Wait(ctx context.Context, id string) <-chan ExitEvent
 }
@@ -0,0 +1,191 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+	"vcr/internal/config"
+	"vcr/internal/runtime"
+)
+
+type killCall struct {
+	id     string
+	signal string
+	// remaining is the time left until the deadline of the context when the signal was sent
+	remaining time.Duration
+}
+
+// fakeRuntime runs containers that exit on SIGKILL and, unless ignoreStopSignal is set, on any other signal.
+type fakeRuntime struct {
+	mutex            sync.Mutex
+	count            int
+	containers       map[string]*runtime.ContainerInfo
+	exits            map[string]chan runtime.ExitEvent
+	kills            []killCall
+	ignoreStopSignal bool
+	// peak is the maximum amount of containers that have been running simultaneously
+	peak int
+	// args contains the arguments each container has been started with
+	args map[string][]string
+	// runErr is returned by Run while failingRuns is positive
+	runErr      error
+	failingRuns int
+}
+
+func newFakeRuntime() *fakeRuntime {
+	return &fakeRuntime{
+		containers: map[string]*runtime.ContainerInfo{},
+		exits:      map[string]chan runtime.ExitEvent{},
+		args:       map[string][]string{},
+	}
+}
+
+func (f *fakeRuntime) Pull(_ context.Context, _ string) error {
+	return nil
+}
+
+func (f *fakeRuntime) Run(_ context.Context, name string, conf config.ContainerConfig) (string, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.failingRuns > 0 {
+		f.failingRuns--
+		return "", f.runErr
+	}
+
+	f.count++
+	id := fmt.Sprintf("%s-%d", name, f.count)
+	f.containers[id] = &runtime.ContainerInfo{Id: id, Name: name, Running: true, Created: time.Now()}
+	f.exits[id] = make(chan runtime.ExitEvent, 1)
+	f.args[id] = conf.Args
+
+	running := 0
+	for _, info := range f.containers {
+		if info.Running {
+			running++
+		}
+	}
+	if running > f.peak {
+		f.peak = running
+	}
+	return id, nil
+}
+
+// add registers a running container, e.g. one that has been left running by a previous vcr process.
+func (f *fakeRuntime) add(id, name string, created time.Time) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.containers[id] = &runtime.ContainerInfo{Id: id, Name: name, Running: true, Created: created}
+	f.exits[id] = make(chan runtime.ExitEvent, 1)
+}
+
+func (f *fakeRuntime) FindByName(name string) (string, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	for id, info := range f.containers {
+		if info.Name == name && info.Running {
+			return id, nil
+		}
+	}
+	return "", runtime.ErrContainerNotFound
+}
+
+func (f *fakeRuntime) DeleteContainer(_ context.Context, id string) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	delete(f.containers, id)
+	return nil
+}
+
+func (f *fakeRuntime) KillContainer(ctx context.Context, id string, signal string) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	call := killCall{id: id, signal: signal}
+	if deadline, ok := ctx.Deadline(); ok {
+		call.remaining = time.Until(deadline)
+	}
+	f.kills = append(f.kills, call)
+
+	info, ok := f.containers[id]
+	if !ok {
+		return runtime.ErrContainerNotFound
+	}
+	if signal != runtime.SignalKill && f.ignoreStopSignal {
+		return nil
+	}
+	if info.Running {
+		info.Running = false
+		info.Finished = time.Now()
+		f.exits[id] <- runtime.ExitEvent{ExitCode: 0}
+	}
+	return nil
+}
+
+func (f *fakeRuntime) ListContainers(_ context.Context) ([]runtime.ContainerInfo, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	var ret []runtime.ContainerInfo
+	for _, info := range f.containers {
+		ret = append(ret, *info)
+	}
+	return ret, nil
+}
+
+func (f *fakeRuntime) Wait(ctx context.Context, id string) <-chan runtime.ExitEvent {
+	f.mutex.Lock()
+	exits := f.exits[id]
+	f.mutex.Unlock()
+
+	ret := make(chan runtime.ExitEvent, 1)
+	go func() {
+		select {
+		case event := <-exits:
+			ret <- event
+		case <-ctx.Done():
+			ret <- runtime.ExitEvent{ExitCode: -1, Err: ctx.Err()}
+		}
+	}()
+	return ret
+}
+
+// running returns the ids of all running containers.
+func (f *fakeRuntime) running() []string {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	var ret []string
+	for id, info := range f.containers {
+		if info.Running {
+			ret = append(ret, id)
+		}
+	}
+	return ret
+}
+
+func (f *fakeRuntime) argsOf(id string) []string {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.args[id]
+}
+
+func (f *fakeRuntime) peakRunning() int {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.peak
+}
+
+func (f *fakeRuntime) killCalls() []killCall {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return append([]killCall(nil), f.kills...)
+}
@@ -0,0 +1,158 @@
+package internal
+
+import (
+	"container/heap"
+	"sync"
+	"time"
+	"vcr/internal/config"
+)
+
+type timerEntry struct {
+	at          time.Time
+	version     uint64
+	programming config.Programming
+	occurrence  config.Occurrence
+}
+
+type timerHeap []*timerEntry
+
+func (h timerHeap) Len() int           { return len(h) }
+func (h timerHeap) Less(i, j int) bool { return h[i].at.Before(h[j].at) }
+func (h timerHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+
+func (h *timerHeap) Push(x any) {
+	*h = append(*h, x.(*timerEntry))
+}
+
+func (h *timerHeap) Pop() any {
+	old := *h
+	n := len(old)
+	entry := old[n-1]
+	old[n-1] = nil
+	*h = old[:n-1]
+	return entry
+}
+
+// scheduler keeps the next occurrence of each programming in a heap ordered by the time its recording needs to be
+// created. Entries are invalidated lazily: each change to a programming increases its version and entries with an
+// outdated version are discarded when they're popped. Only the goroutine running the control loop may access the
+// heap, changes are signalled via notify and notifyAll.
+type scheduler struct {
+	leadTime time.Duration
+	// padding is the default padding of programmings without their own padding
+	padding  config.Padding
+	heap     timerHeap
+	versions map[string]uint64
+
+	mutex     sync.Mutex
+	pending   map[string]struct{}
+	reloadAll bool
+	wakeup    chan struct{}
+}
+
+func newScheduler(leadTime time.Duration) *scheduler {
+	return &scheduler{
+		leadTime: leadTime,
+		versions: map[string]uint64{},
+		pending:  map[string]struct{}{},
+		wakeup:   make(chan struct{}, 1),
+	}
+}
+
+// notify signals that the programming with the given name has changed.
+func (s *scheduler) notify(name string) {
+	s.mutex.Lock()
+	s.pending[name] = struct{}{}
+	s.mutex.Unlock()
+	s.signal()
+}
+
+// notifyAll signals that all programmings need to be reloaded.
+func (s *scheduler) notifyAll() {
+	s.mutex.Lock()
+	s.reloadAll = true
+	s.mutex.Unlock()
+	s.signal()
+}
+
+func (s *scheduler) signal() {
+	select {
+	case s.wakeup <- struct{}{}:
+	default:
+	}
+}
+
+func (s *scheduler) drain() (names []string, all bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for name := range s.pending {
+		names = append(names, name)
+	}
+	all = s.reloadAll
+	s.pending = map[string]struct{}{}
+	s.reloadAll = false
+	return names, all
+}
+
+func (s *scheduler) reset() {
+	s.heap = nil
+	s.versions = map[string]uint64{}
+}
+
+// plan invalidates all entries of the programming and adds its next occurrence.
+func (s *scheduler) plan(programming config.Programming, now time.Time) {
+	s.remove(programming.Name)
+	occurrence, ok := programming.NextOccurrence(now)
+	if ok {
+		s.push(programming, occurrence, s.versions[programming.Name])
+	}
+}
+
+func (s *scheduler) remove(name string) {
+	s.versions[name]++
+}
+
+func (s *scheduler) push(programming config.Programming, occurrence config.Occurrence, version uint64) {
+	heap.Push(&s.heap, &timerEntry{
+		at:          s.dueAt(programming, occurrence),
+		version:     version,
+		programming: programming,
+		occurrence:  occurrence,
+	})
+}
+
+// dueAt returns the time the recording of the occurrence needs to be created.
+func (s *scheduler) dueAt(programming config.Programming, occurrence config.Occurrence) time.Time {
+	padding := programming.PaddingOrDefault(s.padding)
+	return occurrence.Start.Add(-padding.Before - s.leadTime)
+}
+
+// popDue returns all valid entries that are due. For recurring programmings, the following occurrence is added.
+func (s *scheduler) popDue(now time.Time) []*timerEntry {
+	var due []*timerEntry
+	for len(s.heap) > 0 && !s.heap[0].at.After(now) {
+		entry := heap.Pop(&s.heap).(*timerEntry)
+		if entry.version != s.versions[entry.programming.Name] {
+			continue
+		}
+		due = append(due, entry)
+
+		next, ok := entry.programming.NextOccurrence(entry.occurrence.Start)
+		if ok {
+			s.push(entry.programming, next, entry.version)
+		}
+	}
+	return due
+}
+
+// next returns the duration until the next entry is due.
+func (s *scheduler) next(now time.Time) (time.Duration, bool) {
+	for len(s.heap) > 0 {
+		if s.heap[0].version == s.versions[s.heap[0].programming.Name] {
+			return s.heap[0].at.Sub(now), true
+		}
+		heap.Pop(&s.heap)
+	}
+	return 0, false
+}
@@ -0,0 +1,140 @@
+package internal
+
+import (
+	"testing"
+	"time"
+	"vcr/internal/config"
+)
+
+func scheduledProgramming(name string, start time.Time) config.Programming {
+	until := start.Add(time.Hour)
+	return config.Programming{Name: name, Url: "https://example.com/" + name, Date: start, Until: &until}
+}
+
+// dueNames returns the names and starts of the entries.
+func dueNames(entries []*timerEntry) []string {
+	var ret []string
+	for _, entry := range entries {
+		ret = append(ret, entry.programming.Name+"@"+entry.occurrence.Start.Format("01-02T15:04"))
+	}
+	return ret
+}
+
+func equalNames(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestScheduler_InvalidatesOutdatedEntries(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	s := newScheduler(time.Minute)
+
+	s.plan(scheduledProgramming("news", now.Add(time.Hour)), now)
+	s.plan(scheduledProgramming("sports", now.Add(2*time.Hour)), now)
+	// planning again replaces the entry, it must not be returned twice
+	s.plan(scheduledProgramming("news", now.Add(3*time.Hour)), now)
+	s.plan(scheduledProgramming("weather", now.Add(4*time.Hour)), now)
+	s.remove("weather")
+
+	wait, ok := s.next(now)
+	if !ok || wait != 2*time.Hour-time.Minute {
+		t.Fatalf("next() = %v, %v, want sports due in %v", wait, ok, 2*time.Hour-time.Minute)
+	}
+	if due := s.popDue(now.Add(time.Hour)); len(due) != 0 {
+		t.Errorf("popDue() = %v, want outdated entry of news to be discarded", dueNames(due))
+	}
+
+	due := s.popDue(now.Add(5 * time.Hour))
+	if want := []string{"sports@03-01T14:00", "news@03-01T15:00"}; !equalNames(dueNames(due), want) {
+		t.Errorf("popDue() = %v, want %v", dueNames(due), want)
+	}
+	if wait, ok := s.next(now); ok {
+		t.Errorf("next() = %v, want no entries after the removal of weather", wait)
+	}
+}
+
+func TestScheduler_PopDuePushesNextOccurrence(t *testing.T) {
+	seriesStart := time.Date(2024, 3, 1, 20, 0, 0, 0, time.UTC)
+	seriesEnd := seriesStart.Add(48 * time.Hour)
+	p := config.Programming{
+		Name:       "news",
+		Url:        "https://example.com/news",
+		Date:       seriesStart,
+		Until:      &seriesEnd,
+		Recurrence: &config.Recurrence{Cron: "0 20 * * *", Duration: 15 * time.Minute, Timezone: "UTC"},
+	}
+	s := newScheduler(time.Minute)
+	s.plan(p, seriesStart.Add(-time.Hour))
+
+	due := s.popDue(seriesStart)
+	if want := []string{"news@03-01T20:00"}; !equalNames(dueNames(due), want) {
+		t.Fatalf("popDue() = %v, want %v", dueNames(due), want)
+	}
+	if until := due[0].occurrence.Until; until == nil || !until.Equal(seriesStart.Add(15*time.Minute)) {
+		t.Errorf("occurrence until = %v, want start + duration", until)
+	}
+
+	wait, ok := s.next(seriesStart)
+	if !ok || wait != 24*time.Hour-time.Minute {
+		t.Fatalf("next() = %v, %v, want next occurrence due in %v", wait, ok, 24*time.Hour-time.Minute)
+	}
+
+	// all due occurrences are returned at once, the series ends after the third
+	due = s.popDue(seriesEnd.Add(24 * time.Hour))
+	if want := []string{"news@03-02T20:00", "news@03-03T20:00"}; !equalNames(dueNames(due), want) {
+		t.Errorf("popDue() = %v, want %v", dueNames(due), want)
+	}
+	if wait, ok := s.next(seriesEnd); ok {
+		t.Errorf("next() = %v, want no entries after the end of the series", wait)
+	}
+}
+
+func TestScheduler_DueAt(t *testing.T) {
+	start := time.Date(2024, 3, 1, 20, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name           string
+		leadTime       time.Duration
+		defaultPadding config.Padding
+		padding        *config.Padding
+		want           time.Time
+	}{
+		{name: "lead time", leadTime: 30 * time.Second, want: start.Add(-30 * time.Second)},
+		{name: "default padding", leadTime: 30 * time.Second, defaultPadding: config.Padding{Before: 2 * time.Minute, After: time.Hour}, want: start.Add(-2*time.Minute - 30*time.Second)},
+		{
+			name:           "padding of the programming",
+			leadTime:       time.Minute,
+			defaultPadding: config.Padding{Before: 2 * time.Minute},
+			padding:        &config.Padding{Before: 5 * time.Minute},
+			want:           start.Add(-6 * time.Minute),
+		},
+		{name: "padding of the programming disables default", leadTime: time.Minute, defaultPadding: config.Padding{Before: 2 * time.Minute}, padding: &config.Padding{}, want: start.Add(-time.Minute)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newScheduler(tt.leadTime)
+			s.padding = tt.defaultPadding
+			p := scheduledProgramming("news", start)
+			p.Padding = tt.padding
+
+			if got := s.dueAt(p, p.OccurrenceAt(p.Date)); !got.Equal(tt.want) {
+				t.Errorf("dueAt() = %v, want %v", got, tt.want)
+			}
+
+			// the entry is due at the same time
+			s.plan(p, start.Add(-time.Hour))
+			if due := s.popDue(tt.want.Add(-time.Nanosecond)); len(due) != 0 {
+				t.Errorf("popDue() before due = %v", dueNames(due))
+			}
+			if due := s.popDue(tt.want); len(due) != 1 {
+				t.Errorf("popDue() = %v, want news to be due", dueNames(due))
+			}
+		})
+	}
+}